@@ -0,0 +1,50 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Avatar is an image cropped to a circle, e.g. for profile pictures.
+type Avatar struct {
+	Image     image.Image
+	Size      int         // The diameter of the circle
+	RingWidth float64     // The width of the ring drawn around the circle. Zero draws no ring.
+	RingColor color.Color // The color of the ring. Nil uses the current foreground color.
+}
+
+func NewAvatar(img image.Image, size int) *Avatar {
+	return &Avatar{Image: img, Size: size}
+}
+
+func (a *Avatar) Draw(ctx *gg.Context, cw float64, ch float64) {
+	size := float64(a.Size)
+	ctx.DrawCircle(size/2, size/2, size/2)
+	withClip(ctx, func() {
+		// scale the image to cover the circle and center it, cropping the longer side
+		w, h := float64(a.Image.Bounds().Dx()), float64(a.Image.Bounds().Dy())
+		scale := size / math.Min(w, h)
+		ctx.Translate((size-w*scale)/2, (size-h*scale)/2)
+		ctx.Scale(scale, scale)
+		// the clip mask only applies to gg's own drawing, so the image is drawn through gg even when anti-aliasing is disabled
+		ctx.DrawImageAnchored(a.Image, 0, 0, 0, 0)
+	})
+
+	if a.RingWidth > 0 {
+		ctx.Push()
+		if a.RingColor != nil {
+			ctx.SetColor(a.RingColor)
+		}
+		ctx.SetLineWidth(a.RingWidth)
+		ctx.DrawCircle(size/2, size/2, (size-a.RingWidth)/2)
+		ctx.Stroke()
+		ctx.Pop()
+	}
+}
+
+func (a *Avatar) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return float64(a.Size), float64(a.Size)
+}
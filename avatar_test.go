@@ -0,0 +1,59 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Avatar(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	avatar := NewAvatar(img, 100)
+
+	ctx := gg.NewContext(1024, 1024)
+	w, h := avatar.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 100.0, w)
+	assert.Equal(t, 100.0, h)
+
+	avatar.Draw(ctx, w, h)
+	assert.Equal(t, red, ctx.Image().At(50, 50), "Center of the avatar should show the image")
+	assert.Equal(t, red, ctx.Image().At(50, 2), "Image should be scaled to cover the circle")
+	for _, p := range []image.Point{{0, 0}, {99, 0}, {0, 99}, {99, 99}} {
+		assert.Equal(t, color.RGBA{}, ctx.Image().At(p.X, p.Y), "Corner %v outside the circle should be transparent", p)
+	}
+
+	t.Run("Ring border", func(t *testing.T) {
+		blue := color.RGBA{B: 255, A: 255}
+		ringed := &Avatar{Image: img, Size: 100, RingWidth: 4, RingColor: blue}
+		ctx := gg.NewContext(100, 100)
+		ringed.Draw(ctx, 100, 100)
+		assert.Equal(t, blue, ctx.Image().At(50, 1), "Ring should be drawn along the edge of the circle")
+		assert.Equal(t, red, ctx.Image().At(50, 50))
+	})
+}
+
+func Test_AvatarClipRestored(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	solid := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	draw.Draw(solid, solid.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	face := image.NewRGBA(image.Rect(0, 0, 50, 50))
+	draw.Draw(face, face.Bounds(), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+
+	// the image block is drawn below the avatar in the same column, outside of its circle
+	scene := NewScene(NewPaneWithShape(NewShapeWithObjects([]Column{{Objects: []Tileable{
+		NewAvatar(face, 50),
+		NewImageBlockFromImage(solid, ""),
+	}}}), 100, 0, 0))
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene)
+	require.NoError(t, err)
+	block := c.Layout.Bounds[0][1]
+	center := image.Pt((block.Min.X+block.Max.X)/2, block.Min.Y+30)
+	assert.Equal(t, red, c.Raw.At(center.X, center.Y), "The avatar's clip should not hide the objects drawn after it")
+}
@@ -0,0 +1,52 @@
+package imacon
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	DefaultBadgePadX = 8.0 // The default horizontal padding between a badge's text and its edges
+	DefaultBadgePadY = 4.0 // The default vertical padding between a badge's text and its edges
+)
+
+// Badge is a compact label drawn centered on a rounded, colored background, e.g. for status tags.
+type Badge struct {
+	Text   string
+	Fg     color.Color // The color of the text. Nil uses the current foreground color.
+	Bg     color.Color // The color of the background. Nil leaves the background unfilled.
+	Radius float64     // The corner radius of the background, capped at half the badge height for a pill shape
+	PadX   float64     // The horizontal padding on each side of the text
+	PadY   float64     // The vertical padding above and below the text
+}
+
+func NewBadge(text string, fg color.Color, bg color.Color, radius float64) *Badge {
+	return &Badge{Text: text, Fg: fg, Bg: bg, Radius: radius, PadX: DefaultBadgePadX, PadY: DefaultBadgePadY}
+}
+
+func (b *Badge) Draw(ctx *gg.Context, cw float64, ch float64) {
+	w, h := b.IntrinsicSize(ctx, cw, ch)
+	ctx.Push()
+	if b.Bg != nil {
+		ctx.SetColor(b.Bg)
+		ctx.DrawRoundedRectangle(0, 0, w, h, math.Min(b.Radius, h/2))
+		ctx.Fill()
+	}
+	if b.Fg != nil {
+		ctx.SetColor(b.Fg)
+	}
+	drawStringAnchored(ctx, b.Text, w/2, h/2, 0.5, 0.5, 0)
+	ctx.Pop()
+}
+
+func (b *Badge) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	w, h := ctx.MeasureString(b.Text)
+	return w + 2*b.PadX, h + 2*b.PadY
+}
+
+// Baseline returns the distance from the top of the badge to the baseline of its text.
+func (b *Badge) Baseline(ctx *gg.Context, expectedWidth float64) float64 {
+	return b.PadY + ctx.FontHeight()
+}
@@ -0,0 +1,27 @@
+package imacon
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Badge(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	badge := NewBadge("In stock", color.White, color.RGBA{G: 160, A: 255}, 8)
+
+	textW, textH := ctx.MeasureString("In stock")
+	w, h := badge.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, textW+2*DefaultBadgePadX, w, "Badge width should exceed the text width by the horizontal padding")
+	assert.Equal(t, textH+2*DefaultBadgePadY, h, "Badge height should exceed the text height by the vertical padding")
+
+	t.Run("Rounded background", func(t *testing.T) {
+		ctx := gg.NewContext(int(w), int(h))
+		badge.Draw(ctx, w, h)
+		bg := color.RGBA{G: 160, A: 255}
+		assert.Equal(t, bg, ctx.Image().At(int(w/2), int(DefaultBadgePadY/2)), "Badge background should be filled")
+		assert.Equal(t, color.RGBA{}, ctx.Image().At(0, 0), "Badge corners should be rounded")
+	})
+}
@@ -0,0 +1,38 @@
+package imacon
+
+import (
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// CanvasBorder is a frame drawn around the whole canvas, e.g. for printable cards.
+type CanvasBorder struct {
+	Width  float64     // The stroke width of the border. Zero draws no border.
+	Color  color.Color // The color of the border. Nil uses the accent color of the engine theme, or else the foreground color.
+	Radius float64     // The corner radius of the border. Zero draws square corners.
+	Inset  float64     // The distance between the canvas edges and the outer edge of the border
+}
+
+// draw strokes the border inside a canvas of the given size, in the current transform.
+func (b CanvasBorder) draw(ctx *gg.Context, width float64, height float64) {
+	if b.Width <= 0 {
+		return
+	}
+	ctx.Push()
+	if c := stateOf(ctx).accentColor(b.Color); c != nil {
+		ctx.SetColor(c)
+	}
+	// the stroke is centered on the path, so the path is inset by half the width to keep the border inside the canvas
+	offset := b.Inset + b.Width/2
+	ctx.SetLineWidth(b.Width)
+	x0, y0 := snapStroke(ctx, offset, offset, b.Width)
+	x1, y1 := snapStroke(ctx, width-offset, height-offset, b.Width)
+	if b.Radius > 0 {
+		ctx.DrawRoundedRectangle(x0, y0, x1-x0, y1-y0, b.Radius)
+	} else {
+		ctx.DrawRectangle(x0, y0, x1-x0, y1-y0)
+	}
+	ctx.Stroke()
+	ctx.Pop()
+}
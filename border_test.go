@@ -0,0 +1,31 @@
+package imacon
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CanvasBorder(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	scene := func() *Scene {
+		return NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+	}
+
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, CanvasBorder: CanvasBorder{Width: 4, Color: red}}).Render(scene())
+	require.NoError(t, err)
+	for _, p := range [][2]int{{c.Width / 2, 1}, {c.Width / 2, c.Height - 2}, {1, c.Height / 2}, {c.Width - 2, c.Height / 2}} {
+		assert.Equal(t, red, c.Raw.At(p[0], p[1]), "Border should be drawn along the canvas edge at %v", p)
+	}
+	assert.NotEqual(t, red, c.Raw.At(c.Width/2, 6), "Border should not extend past its width")
+
+	t.Run("Inset and output scale", func(t *testing.T) {
+		border := CanvasBorder{Width: 2, Color: red, Inset: 5}
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Scale: 2, CanvasBorder: border}).Render(scene())
+		require.NoError(t, err)
+		assert.Equal(t, color.RGBA{255, 255, 255, 255}, c.Raw.At(c.Width/2, 5), "Inset should leave the edge uncovered")
+		assert.Equal(t, red, c.Raw.At(c.Width/2, 12), "Border should be drawn in scaled canvas coordinates")
+	})
+}
@@ -0,0 +1,114 @@
+package imacon
+
+import (
+	"fmt"
+	"image"
+)
+
+// SceneBuilder assembles a scene whose main pane has a fixed shape, column by column, as a shorthand for
+// NewPaneWithShape(NewShapeWithObjects([]Column{...})):
+//
+//	scene, err := NewSceneBuilder().
+//		Column().Text("Title", TextBlockOpts{}).Image(img, "Photo").EndColumn().
+//		Column().Width(240).Add(table).EndColumn().
+//		Build()
+//
+// The first misuse, such as adding an object outside of a column, is recorded and returned by Build.
+type SceneBuilder struct {
+	columns  []Column
+	open     bool // Whether the last column is still being filled
+	colWidth float64
+	colPad   float64
+	rowPad   float64
+	err      error
+}
+
+func NewSceneBuilder() *SceneBuilder {
+	return &SceneBuilder{}
+}
+
+// fail records the first error of the builder.
+func (b *SceneBuilder) fail(format string, args ...any) *SceneBuilder {
+	if b.err == nil {
+		b.err = fmt.Errorf("imacon: SceneBuilder: "+format, args...)
+	}
+	return b
+}
+
+// Padding sets the column width and the paddings of the main pane, with zero values falling back to the defaults like NewPane.
+func (b *SceneBuilder) Padding(colWidth float64, colPad float64, rowPad float64) *SceneBuilder {
+	b.colWidth, b.colPad, b.rowPad = colWidth, colPad, rowPad
+	return b
+}
+
+// Column starts a new column. The previous column must have been ended.
+func (b *SceneBuilder) Column() *SceneBuilder {
+	if b.open {
+		return b.fail("Column called before EndColumn")
+	}
+	b.columns = append(b.columns, Column{})
+	b.open = true
+	return b
+}
+
+// Width sets the width of the current column, overriding the pane's column width.
+func (b *SceneBuilder) Width(width float64) *SceneBuilder {
+	if !b.open {
+		return b.fail("Width called outside of a column")
+	}
+	if width < 0 {
+		return b.fail("column width must not be negative, got %v", width)
+	}
+	b.columns[len(b.columns)-1].Width = width
+	return b
+}
+
+// Add appends an object, such as a nested pane, to the current column.
+func (b *SceneBuilder) Add(obj Tileable) *SceneBuilder {
+	if !b.open {
+		return b.fail("object added outside of a column")
+	}
+	if obj == nil {
+		return b.fail("object must not be nil")
+	}
+	column := &b.columns[len(b.columns)-1]
+	column.Objects = append(column.Objects, obj)
+	return b
+}
+
+// Text appends a text block to the current column.
+func (b *SceneBuilder) Text(text string, opts TextBlockOpts) *SceneBuilder {
+	return b.Add(NewTextBlock(text, opts))
+}
+
+// Image appends an image block with the given label to the current column.
+func (b *SceneBuilder) Image(img image.Image, label string) *SceneBuilder {
+	if img == nil {
+		return b.fail("image must not be nil")
+	}
+	return b.Add(NewImageBlockFromImage(img, label))
+}
+
+// EndColumn ends the current column.
+func (b *SceneBuilder) EndColumn() *SceneBuilder {
+	if !b.open {
+		return b.fail("EndColumn called without a column")
+	}
+	b.open = false
+	return b
+}
+
+// Build returns the scene with a main pane of the built columns, or the first error recorded while building.
+func (b *SceneBuilder) Build() (*Scene, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.open {
+		return nil, fmt.Errorf("imacon: SceneBuilder: column %d was not ended", len(b.columns))
+	}
+	if len(b.columns) == 0 {
+		return nil, fmt.Errorf("imacon: SceneBuilder: scene has no columns")
+	}
+	columns := append([]Column(nil), b.columns...)
+	return NewScene(NewPaneWithShape(NewShapeWithObjects(columns), b.colWidth, b.colPad, b.rowPad)), nil
+}
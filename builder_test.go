@@ -0,0 +1,49 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SceneBuilder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{G: 160, A: 255}), image.Point{}, draw.Src)
+	nested := NewPane([]Tileable{NewTextBlock("Nested", TextBlockOpts{})}, 200, 0, 0)
+
+	built, err := NewSceneBuilder().
+		Padding(300, 0, 0).
+		Column().Text("Title", TextBlockOpts{TextWrap: true}).Image(img, "Photo").EndColumn().
+		Column().Width(200).Add(nested).EndColumn().
+		Build()
+	require.NoError(t, err)
+
+	manual := NewScene(NewPaneWithShape(NewShapeWithObjects([]Column{
+		{Objects: []Tileable{NewTextBlock("Title", TextBlockOpts{TextWrap: true}), NewImageBlockFromImage(img, "Photo")}},
+		{Objects: []Tileable{nested}, Width: 200},
+	}), 300, 0, 0))
+	assert.Equal(t, manual, built, "The builder should produce the same structures as the manual API")
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	builtCanvas, err := eng.Render(built)
+	require.NoError(t, err)
+	manualCanvas, err := eng.Render(manual)
+	require.NoError(t, err)
+	assert.Equal(t, manualCanvas.Raw, builtCanvas.Raw, "The built scene should render identically to the manual scene")
+
+	for name, b := range map[string]*SceneBuilder{
+		"object outside of a column": NewSceneBuilder().Text("Loose", TextBlockOpts{}),
+		"nested column":              NewSceneBuilder().Column().Column(),
+		"unended column":             NewSceneBuilder().Column().Text("Open", TextBlockOpts{}),
+		"end without a column":       NewSceneBuilder().EndColumn(),
+		"no columns":                 NewSceneBuilder(),
+		"nil image":                  NewSceneBuilder().Column().Image(nil, "").EndColumn(),
+	} {
+		_, err := b.Build()
+		assert.Error(t, err, "Build should fail for %s", name)
+	}
+}
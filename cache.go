@@ -0,0 +1,55 @@
+package imacon
+
+import (
+	"image"
+	"io"
+	"sync"
+)
+
+// ImageCache memoizes decoded images by a caller-provided key, such as a file path or URL, so that scenes sharing the same
+// images don't decode them again. It is safe for concurrent use.
+type ImageCache struct {
+	mu     sync.RWMutex
+	images map[string]image.Image
+}
+
+func NewImageCache() *ImageCache {
+	return &ImageCache{images: make(map[string]image.Image)}
+}
+
+// Get returns the cached image for the key, if any.
+func (c *ImageCache) Get(key string) (image.Image, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	img, ok := c.images[key]
+	return img, ok
+}
+
+// Decode returns the cached image for the key, decoding and caching it from the reader if absent.
+// The reader is not read when the image is already cached.
+func (c *ImageCache) Decode(key string, file io.Reader) (image.Image, error) {
+	if img, ok := c.Get(key); ok {
+		return img, nil
+	}
+	// decode outside of the lock; concurrent misses on the same key may decode twice, but store the same result
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.images[key]; ok {
+		return cached, nil
+	}
+	c.images[key] = img
+	return img, nil
+}
+
+// NewImageBlockCached creates an image block like NewImageBlock, reusing the decoded image stored in the cache under key.
+func NewImageBlockCached(cache *ImageCache, key string, file io.Reader, label string) (*ImageBlock, error) {
+	img, err := cache.Decode(key, file)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageBlockFromImage(img, label), nil
+}
@@ -0,0 +1,68 @@
+package imacon
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func Test_ImageCache(t *testing.T) {
+	const path = "assets/samples/sample_1.jpg"
+	cache := NewImageCache()
+
+	open := func() *countingReader {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { f.Close() })
+		return &countingReader{r: f}
+	}
+
+	first := open()
+	b1, err := NewImageBlockCached(cache, path, first, "First")
+	require.NoError(t, err)
+	assert.Greater(t, first.n, 0, "First lookup should decode from the reader")
+
+	second := open()
+	b2, err := NewImageBlockCached(cache, path, second, "Second")
+	require.NoError(t, err)
+	assert.Equal(t, 0, second.n, "Second lookup should not read from the reader")
+	assert.Same(t, b1.Image, b2.Image, "Both blocks should share the decoded image")
+	assert.Equal(t, "Second", b2.Label.Text)
+
+	t.Run("Concurrent lookups", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f, err := os.Open("assets/samples/sample_3.jpg")
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer f.Close()
+				_, err = NewImageBlockCached(cache, "sample_3", f, "Necklace")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		img, ok := cache.Get("sample_3")
+		assert.True(t, ok)
+		assert.NotNil(t, img)
+	})
+}
@@ -0,0 +1,82 @@
+package imacon
+
+import "slices"
+
+// Clone returns a deep copy of the scene, so that a template scene can be rendered in variations, e.g. concurrently,
+// without the layout cached by one render leaking into another. The panes are copied with their planned shapes
+// reset, and so are the text and image blocks with their labels, while the decoded images themselves are shared.
+// Objects of other types are shared as they are.
+func (s *Scene) Clone() *Scene {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	clone.Main = s.Main.Clone()
+	clone.Header = s.Header.Clone()
+	clone.Footer = s.Footer.Clone()
+	clone.Panes = nil
+	for _, pane := range s.Panes {
+		clone.Panes = append(clone.Panes, pane.Clone())
+	}
+	clone.PaneWidths = slices.Clone(s.PaneWidths)
+	clone.Overlays = slices.Clone(s.Overlays)
+	for i, overlay := range clone.Overlays {
+		if obj, ok := overlay.Object.(Tileable); ok {
+			clone.Overlays[i].Object = cloneObject(obj)
+		}
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the pane and its objects like Scene.Clone, without the shape cached by a render, so that
+// the copy is laid out afresh when rendered. A pane holding its objects only in a planned shape, as made by
+// NewPaneWithShape, SceneBuilder and NewContactSheet, keeps a deep copy of its shape.
+func (p *Pane) Clone() *Pane {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	clone.PlannedShape = nil
+	clone.maxWidth = 0
+	clone.widestObject = 0
+	clone.Objects = make([]Tileable, len(p.Objects))
+	for i, obj := range p.Objects {
+		clone.Objects[i] = cloneObject(obj)
+	}
+	if len(p.Objects) == 0 && p.PlannedShape != nil {
+		shape := Shape{Columns: slices.Clone(p.PlannedShape.Columns)}
+		for i, column := range shape.Columns {
+			shape.Columns[i].Objects = make([]Tileable, len(column.Objects))
+			for j, obj := range column.Objects {
+				shape.Columns[i].Objects[j] = cloneObject(obj)
+			}
+		}
+		clone.PlannedShape = &shape
+	}
+	return &clone
+}
+
+// cloneObject returns a copy of a pane object for Pane.Clone, recursing into nested panes and wrapped objects.
+func cloneObject(obj Tileable) Tileable {
+	switch o := obj.(type) {
+	case *Pane:
+		return o.Clone()
+	case *Margined:
+		return &Margined{Object: cloneObject(o.Object), Margin: o.Margin}
+	case *TileProxy:
+		return &TileProxy{Object: cloneObject(o.Object), Size: o.Size}
+	case *TextBlock:
+		clone := *o
+		return &clone
+	case *ImageBlock:
+		clone := &ImageBlock{Image: o.Image, Label: o.Label, Opts: o.Opts}
+		if o.Label != nil {
+			label := *o.Label
+			clone.Label = &label
+		}
+		return clone
+	case *contactCell:
+		return &contactCell{block: cloneObject(o.block).(*ImageBlock), size: o.size}
+	}
+	return obj
+}
@@ -0,0 +1,81 @@
+package imacon
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SceneClone(t *testing.T) {
+	nested := NewPane([]Tileable{
+		NewTextBlock("First", TextBlockOpts{}),
+		NewTextBlock("Second", TextBlockOpts{}),
+	}, 0, 0, 0)
+	photo := loadImageBlock(t, "assets/samples/glasses.png", "Glasses")
+	template := NewScene(NewPane([]Tileable{photo, NewMargined(nested, Margin{Top: 4})}, 200, 0, 0))
+	template.Header = NewPane([]Tileable{NewTextBlock("Title", TextBlockOpts{})}, 0, 0, 0)
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	_, err := eng.Render(template.Clone())
+	require.NoError(t, err)
+	assert.Nil(t, template.Main.PlannedShape, "Rendering a clone should not lay out the template")
+	assert.Nil(t, nested.PlannedShape, "Nested panes should be cloned too")
+	assert.Nil(t, template.Header.PlannedShape)
+
+	want, err := eng.Render(template)
+	require.NoError(t, err)
+	shape := template.Main.PlannedShape
+	require.NotNil(t, shape)
+
+	// variations of the template render concurrently without sharing layout state
+	clones := make([]*Scene, 4)
+	for i := range clones {
+		clones[i] = template.Clone()
+		assert.Nil(t, clones[i].Main.PlannedShape, "Clones should be laid out afresh")
+		clones[i].Main.Objects[0].(*ImageBlock).Label.Text = "Variation"
+	}
+	var wg sync.WaitGroup
+	for _, clone := range clones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := eng.Render(clone)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Same(t, shape, template.Main.PlannedShape, "Rendering clones should not replace the template's cached shape")
+	assert.Equal(t, "Glasses", photo.Label.Text, "Editing a clone should not change the template")
+	assert.Same(t, photo.Image, clones[0].Main.Objects[0].(*ImageBlock).Image, "Images should be shared")
+
+	got, err := eng.Render(template)
+	require.NoError(t, err)
+	assert.Equal(t, want.Raw, got.Raw, "The template should render as before")
+}
+
+func Test_SceneCloneOfPlannedPanes(t *testing.T) {
+	photo := loadImageBlock(t, "assets/samples/glasses.png", "Glasses")
+	built, err := NewSceneBuilder().
+		Column().Text("Title", TextBlockOpts{}).Add(photo).EndColumn().
+		Column().Add(NewContactSheet([]*ImageBlock{photo}, 1, Size{Width: 80, Height: 80})).EndColumn().
+		Build()
+	require.NoError(t, err)
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	clone := built.Clone()
+	require.NotNil(t, clone.Main.PlannedShape, "Planned shapes should be kept by the clone")
+	assert.NotSame(t, built.Main.PlannedShape, clone.Main.PlannedShape)
+	cloned := clone.Main.PlannedShape.Columns[0].Objects[1].(*ImageBlock)
+	assert.NotSame(t, photo, cloned, "The objects of a planned shape should be cloned")
+	cloned.Label.Text = "Variation"
+
+	got, err := eng.Render(clone)
+	require.NoError(t, err)
+	want, err := eng.Render(built)
+	require.NoError(t, err)
+	assert.Equal(t, "Glasses", photo.Label.Text, "Editing a clone should not change the original")
+	assert.Equal(t, want.Width, got.Width)
+	assert.NotEqual(t, want.Raw, got.Raw, "The clone should render its own label")
+}
@@ -0,0 +1,28 @@
+package imacon
+
+import (
+	"image/color"
+	"math"
+)
+
+// relativeLuminance returns the WCAG relative luminance of the color, from 0 for black to 1 for white.
+func relativeLuminance(c color.Color) float64 {
+	r, g, b, _ := color.NRGBAModel.Convert(c).RGBA()
+	linear := func(v uint32) float64 {
+		s := float64(v) / 0xffff
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// contrastColor returns black or white, whichever has the higher contrast ratio against the given color.
+func contrastColor(c color.Color) color.Color {
+	// the luminance at which black and white have equal contrast ratios
+	if relativeLuminance(c) > 0.179 {
+		return color.Black
+	}
+	return color.White
+}
@@ -0,0 +1,30 @@
+package imacon
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AutoContrastText(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{NewDivider(4, nil)}, 100, 0, 0))
+	}
+	// the divider is drawn in the foreground color just inside the outer padding
+	fgOf := func(bg color.Color) color.Color {
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, BgColor: bg, AutoContrastText: true}).Render(newScene())
+		require.NoError(t, err)
+		return c.Raw.At(int(DefaultOuterPad)+1, int(DefaultOuterPad)+1)
+	}
+
+	light := fgOf(color.RGBA{R: 250, G: 240, B: 200, A: 255})
+	dark := fgOf(color.RGBA{R: 20, G: 30, B: 60, A: 255})
+	assert.Equal(t, color.RGBAModel.Convert(color.Black), light, "Light background should get black text")
+	assert.Equal(t, color.RGBAModel.Convert(color.White), dark, "Dark background should get white text")
+
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, BgColor: color.Black, FgColor: color.Black, AutoContrastText: true}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBAModel.Convert(color.Black), c.Raw.At(int(DefaultOuterPad)+1, int(DefaultOuterPad)+1), "Explicit FgColor should take precedence")
+}
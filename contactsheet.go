@@ -0,0 +1,56 @@
+package imacon
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// contactCell is a fixed-size cell of a contact sheet, holding an image block scaled down to fit and centered.
+type contactCell struct {
+	block *ImageBlock
+	size  Size
+}
+
+// NewContactSheet creates a pane laying the image blocks out in a fixed grid of cols columns, filled row by row,
+// where every cell has the given size regardless of the aspect ratio of its image. Unlike the greedy tiling of NewPane,
+// images and their captions are scaled down to fit their cell and centered in it. cols is clamped to [1, len(images)].
+func NewContactSheet(images []*ImageBlock, cols int, cellSize Size) *Pane {
+	cols = max(min(cols, len(images)), 1)
+	columns := make([]Column, cols)
+	for i, block := range images {
+		column := &columns[i%cols]
+		column.Objects = append(column.Objects, &contactCell{block: block, size: cellSize})
+	}
+	return NewPaneWithShape(NewShapeWithObjects(columns), cellSize.Width, 0, 0)
+}
+
+// fit returns the width to draw the block at so that the image and its caption fit within the cell, and the
+// resulting height of the block.
+func (c *contactCell) fit(ctx *gg.Context) (float64, float64) {
+	width, _ := c.block.imageSize(c.size.Width, 0)
+	// the caption rewraps to the narrower width, so the fit is refined once with the caption measured at the first fit
+	for range 2 {
+		_, blockHeight := c.block.IntrinsicSize(ctx, width, 0)
+		_, imageHeight := c.block.imageSize(width, 0)
+		captionHeight := blockHeight - imageHeight
+		// the image is only scaled down, never up, into the room left by its caption
+		scale := math.Min(1, math.Max(c.size.Height-captionHeight, 1)/imageHeight)
+		width = math.Max(width*scale, 1)
+	}
+	_, height := c.block.IntrinsicSize(ctx, width, 0)
+	return width, height
+}
+
+func (c *contactCell) Draw(ctx *gg.Context, cw float64, ch float64) {
+	width, height := c.fit(ctx)
+	ctx.DrawRectangle(0, 0, c.size.Width, c.size.Height)
+	withClip(ctx, func() {
+		ctx.Translate((c.size.Width-width)/2, math.Max(c.size.Height-height, 0)/2)
+		c.block.Draw(ctx, width, height)
+	})
+}
+
+func (c *contactCell) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return c.size.Width, c.size.Height
+}
@@ -0,0 +1,76 @@
+package imacon
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewContactSheet(t *testing.T) {
+	var images []*ImageBlock
+	for i, size := range []image.Point{{400, 300}, {120, 500}, {60, 40}, {300, 300}, {800, 100}} {
+		img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+		images = append(images, NewImageBlockFromImage(img, fmt.Sprintf("Image %d", i+1)))
+	}
+	sheet := NewContactSheet(images, 3, Size{Width: 160, Height: 140})
+	require.Len(t, sheet.PlannedShape.Columns, 3)
+	assert.Len(t, sheet.PlannedShape.Columns[0].Objects, 2, "Cells should be filled row by row")
+	assert.Len(t, sheet.PlannedShape.Columns[2].Objects, 1)
+
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(sheet))
+	require.NoError(t, err)
+	var cells []image.Rectangle
+	for _, column := range c.Layout.Bounds {
+		cells = append(cells, column...)
+	}
+	require.Len(t, cells, len(images))
+	for _, cell := range cells {
+		assert.Equal(t, image.Pt(160, 140), cell.Size(), "Every cell should have the same size")
+	}
+	bounds := c.Layout.Bounds
+	assert.Equal(t, bounds[0][0].Min.Y, bounds[1][0].Min.Y, "Cells of a row should be aligned")
+	assert.Equal(t, bounds[0][0].Max.X+DefaultColPad, bounds[1][0].Min.X)
+
+	// the tall image is scaled down to leave room for its caption and centered horizontally
+	red := color.RGBA{R: 200, A: 255}
+	tall := bounds[1][0]
+	assert.Equal(t, red, c.Raw.At(tall.Min.X+80, tall.Min.Y+10))
+	assert.NotEqual(t, red, c.Raw.At(tall.Min.X+5, tall.Min.Y+10))
+	// the small image is not scaled up
+	small := bounds[2][0]
+	assert.Equal(t, red, c.Raw.At(small.Min.X+80, small.Min.Y+60))
+	assert.NotEqual(t, red, c.Raw.At(small.Min.X+80-35, small.Min.Y+60))
+
+	assert.Len(t, NewContactSheet(images, 0, Size{Width: 100, Height: 100}).PlannedShape.Columns, 1)
+	assert.Len(t, NewContactSheet(images, 10, Size{Width: 100, Height: 100}).PlannedShape.Columns, len(images))
+}
+
+func Test_ContactSheetKeepsClip(t *testing.T) {
+	red := color.RGBA{R: 200, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	var images []*ImageBlock
+	for range 2 {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+		images = append(images, NewImageBlockFromImage(img, ""))
+	}
+	sheet := NewContactSheet(images, 2, Size{Width: 100, Height: 100})
+
+	// the caller clips the context to the first cell, so the second cell must not be drawn
+	ctx := gg.NewContext(400, 200)
+	ctx.SetColor(green)
+	ctx.Clear()
+	ctx.DrawRectangle(0, 0, DefaultOuterPad+100, 200)
+	ctx.Clip()
+	require.NoError(t, New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).DrawOnto(ctx, NewScene(sheet), 0, 0))
+	assert.Equal(t, red, ctx.Image().At(int(DefaultOuterPad)+50, int(DefaultOuterPad)+50))
+	second := int(DefaultOuterPad+100+DefaultColPad) + 50
+	assert.Equal(t, green, ctx.Image().At(second, int(DefaultOuterPad)+50), "Cells should stay within the caller's clip")
+}
@@ -0,0 +1,43 @@
+package imacon
+
+import (
+	"image"
+	"math"
+)
+
+// roundCorners clears the pixels outside a rounded rectangle spanning the canvas of the given size, anti-aliasing the
+// arcs. The image holds the canvas rows starting at offsetY, which allows the canvas to be masked in horizontal strips.
+func roundCorners(img *image.RGBA, offsetY int, width int, height int, radius float64) {
+	radius = math.Min(radius, float64(min(width, height))/2)
+	size := int(math.Ceil(radius))
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		canvasY := y - bounds.Min.Y + offsetY
+		if canvasY >= height {
+			break
+		}
+		// the distance of the pixel row from the nearest horizontal edge, only the rows within the radius are masked
+		edgeY := min(canvasY, height-1-canvasY)
+		if edgeY >= size {
+			continue
+		}
+		for edgeX := range min(size, width) {
+			// coverage of the pixel center by the corner circle, blended over one pixel for anti-aliasing
+			dx, dy := radius-(float64(edgeX)+0.5), radius-(float64(edgeY)+0.5)
+			coverage := 1.0
+			if dx > 0 && dy > 0 {
+				coverage = math.Max(0, math.Min(1, radius-math.Hypot(dx, dy)+0.5))
+			}
+			if coverage == 1 {
+				continue
+			}
+			for _, x := range []int{bounds.Min.X + edgeX, bounds.Min.X + width - 1 - edgeX} {
+				// the pixels are premultiplied, so every channel is scaled by the coverage
+				pix := img.Pix[img.PixOffset(x, y) : img.PixOffset(x, y)+4]
+				for i := range pix {
+					pix[i] = uint8(math.Round(float64(pix[i]) * coverage))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package imacon
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CornerRadius(t *testing.T) {
+	scene := func() *Scene {
+		return NewScene(NewPane([]Tileable{NewTextBlock("Rounded", TextBlockOpts{})}, 300, 0, 0))
+	}
+	cfg := Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, CornerRadius: 20}
+	c, err := New(cfg).Render(scene())
+	require.NoError(t, err)
+
+	alphaAt := func(img image.Image, x int, y int) uint32 {
+		_, _, _, a := img.At(x, y).RGBA()
+		return a
+	}
+	w, h := c.Width, c.Height
+	for _, corner := range []image.Point{{0, 0}, {w - 1, 0}, {0, h - 1}, {w - 1, h - 1}, {2, 2}} {
+		assert.Zero(t, alphaAt(c.Raw, corner.X, corner.Y), "Corner pixel %v should be transparent", corner)
+	}
+	for _, inside := range []image.Point{{w / 2, h / 2}, {w / 2, 0}, {0, h / 2}, {20, 20}, {w - 21, h - 21}} {
+		assert.Equal(t, uint32(0xffff), alphaAt(c.Raw, inside.X, inside.Y), "Pixel %v should be opaque", inside)
+	}
+	// the arc is anti-aliased with partially covered pixels
+	partial := false
+	for x := range 20 {
+		if a := alphaAt(c.Raw, x, 6); a != 0 && a != 0xffff {
+			partial = true
+		}
+	}
+	assert.True(t, partial)
+
+	square, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xffff), alphaAt(square.Raw, 0, 0), "Corners should be square by default")
+
+	t.Run("Streamed", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, New(cfg).RenderTo(scene(), &buf, FormatPNG))
+		streamed, err := png.Decode(&buf)
+		require.NoError(t, err)
+		for _, p := range []image.Point{{0, 0}, {w - 1, h - 1}, {w / 2, h / 2}, {3, 10}} {
+			assert.Equal(t, alphaAt(c.Raw, p.X, p.Y), alphaAt(streamed, p.X, p.Y), "Streamed output should be masked like Render at %v", p)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package imacon
+
+import (
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// Divider is a horizontal rule spanning the width of its column.
+type Divider struct {
+	Thickness float64     // The thickness of the rule
+	Color     color.Color // The color of the rule. Nil uses the accent color of the engine theme, or else the current foreground color.
+}
+
+func NewDivider(thickness float64, c color.Color) *Divider {
+	if thickness == 0 {
+		thickness = 1
+	}
+	return &Divider{Thickness: thickness, Color: c}
+}
+
+func (d *Divider) Draw(ctx *gg.Context, cw float64, ch float64) {
+	ctx.Push()
+	if c := stateOf(ctx).accentColor(d.Color); c != nil {
+		ctx.SetColor(c)
+	}
+	// the rule is snapped even with anti-aliasing, so that a thin rule stays crisp at fractional offsets
+	fillSnappedRect(ctx, 0, 0, cw, d.Thickness)
+	ctx.Pop()
+}
+
+func (d *Divider) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return expectedWidth, d.Thickness
+}
@@ -0,0 +1,63 @@
+package imacon
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Divider(t *testing.T) {
+	// the margin puts the divider on a half-pixel boundary
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewMargined(NewDivider(2, nil), Margin{Top: 0.5}),
+		}, 200, 0, 0))
+	}
+
+	// intermediateRows counts the rows along the center column that are neither background nor foreground
+	intermediateRows := func(c *Canvas) int {
+		count := 0
+		for y := range c.Height {
+			r, _, _, _ := c.Raw.At(c.Width/2, y).RGBA()
+			if r != 0 && r != 0xffff {
+				count++
+			}
+		}
+		return count
+	}
+
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, 0, intermediateRows(c), "Divider should be snapped to whole pixels")
+
+	c, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, DisableAntialias: true}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, 0, intermediateRows(c), "Divider should have hard edges when anti-aliasing is disabled")
+	solid := 0
+	for y := range c.Height {
+		if c.Raw.At(c.Width/2, y) == (color.RGBA{A: 255}) {
+			solid++
+		}
+	}
+	assert.Equal(t, 2, solid, "Divider should cover exactly its thickness in pixels")
+}
+
+func Test_DividerPixelSnap(t *testing.T) {
+	// the margin puts the 1 pixel divider between two rows
+	scene := NewScene(NewPane([]Tileable{
+		NewMargined(NewDivider(1, nil), Margin{Top: 10.3}),
+	}, 200, 0, 0))
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene)
+	require.NoError(t, err)
+	var rows []int
+	for y := range c.Raw.Bounds().Dy() {
+		r, _, _, _ := c.Raw.At(c.Raw.Bounds().Dx()/2, y).RGBA()
+		if r != 0xffff {
+			rows = append(rows, y)
+			assert.Equal(t, color.RGBA{A: 255}, c.Raw.At(c.Raw.Bounds().Dx()/2, y), "Row %d should be fully opaque", y)
+		}
+	}
+	assert.Len(t, rows, 1, "A 1 pixel divider should cover a single row")
+}
@@ -6,19 +6,34 @@ package imacon
 // 3. Pane - A container that holds Texts and Images, with layout properties such as padding, margin. Support object alignment within the pane. Support auto-tiling of objects to match the best output size efficiency.
 
 import (
+	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	_ "image/gif"
 	"image/jpeg"
 	_ "image/jpeg"
 	"image/png"
 	_ "image/png"
 	"io"
 	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fogleman/gg"
 	"github.com/golang/freetype/truetype"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/f64"
+	_ "golang.org/x/image/tiff"
 )
 
 //go:embed assets/fonts/JetBrainsMono-Regular.ttf
@@ -33,10 +48,26 @@ const (
 	DefaultMaxFontSize = 32.0  // The default maximum font size
 	DefaultColWidth    = 720.0 // The default column width for tiling
 	DefaultColPad      = 24.0  // The default padding between columns
+	DefaultBandPad     = 12.0  // The default padding between the header or footer band and the main pane
+
+	DefaultPlaceholderWidth  = 320 // The default width of the placeholder for images that fail to load
+	DefaultPlaceholderHeight = 240 // The default height of the placeholder for images that fail to load
 )
 
+// PlaceholderColor is the neutral fill color of the placeholder for images that fail to load.
+var PlaceholderColor = color.RGBA{R: 204, G: 204, B: 204, A: 255}
+
+// DebugGridColor is the faint color used to outline the columns and objects when Config.DebugGrid is enabled.
+var DebugGridColor = color.NRGBA{R: 255, B: 255, A: 160}
+
 type Engine struct {
 	cfg Config
+
+	measureCtxs sync.Pool // The reusable measurement contexts with the configured font face, since the config never changes
+
+	styleFontsOnce sync.Once
+	styleFonts     map[fontStyle]*truetype.Font // The parsed BoldFont and ItalicFont, by the style they are drawn for
+	styleFontsErr  error
 }
 
 // Drawable defines the behavior of objects that can be drawn onto the scene.
@@ -52,42 +83,741 @@ type Tileable interface {
 
 // The configuration options for the Imacon rendering engine.
 type Config struct {
-	MaxCanvasWidth  int         // The maximum width of the canvas to compose images on.
-	MaxCanvasHeight int         // The maximum height of the canvas to compose images on.
-	FgColor         color.Color // The foreground color used for text and shapes.
-	BgColor         color.Color // The background color of the canvas.
-	FontSize        float64     // The default font size for text rendering.
+	MaxCanvasWidth     int                       // The maximum width of the canvas to compose images on. Zero means unbounded.
+	MaxCanvasHeight    int                       // The maximum height of the canvas to compose images on. Zero means unbounded.
+	FgColor            color.Color               // The foreground color used for text and shapes.
+	BgColor            color.Color               // The background color of the canvas.
+	FontSize           float64                   // The default font size for text rendering, clamped to [MinFontSize, MaxFontSize].
+	MinFontSize        float64                   // The smallest allowed font size. Zero defaults to DefaultMinFontSize.
+	MaxFontSize        float64                   // The largest allowed font size. Zero defaults to DefaultMaxFontSize.
+	DefaultJpegQuality int                       // The JPEG quality used by Canvas.ToJpeg when no options are given. Zero uses the jpeg package default.
+	JpegMatte          color.Color               // The color transparent pixels are composited over when encoding to JPEG, which has no alpha. Nil defaults to white.
+	OnProgress         func(done int, total int) // Optional callback invoked after each top-level object of the main pane is drawn.
+	// The output scale factor for high-resolution rendering, e.g. 2 for retina output. Zero defaults to 1.
+	// The layout, MaxCanvasWidth and MaxCanvasHeight are in logical units, so the output pixel size is the logical size multiplied by Scale.
+	// Text glyphs are rasterized at the font size multiplied by Scale, so that they stay as sharp as at 1x. Negative values are rejected.
+	Scale float64
+	// Whether to draw shapes such as dividers with hard, pixel-snapped edges and scale images with nearest-neighbor sampling.
+	// Text is still anti-aliased by the font rasterizer.
+	DisableAntialias bool
+	// Whether to pick black or white text, whichever contrasts best with BgColor, when FgColor is unset.
+	AutoContrastText bool
+	// The alignment of the content within the canvas when it is downscaled to fit the max canvas size and leaves
+	// empty space in one dimension. The zero value keeps the content at the top-left corner.
+	ContentAlign ContentAlign
+	// Whether to outline each column and object of the panes after drawing them, for debugging the layout.
+	// It only affects rendering, not measurement.
+	DebugGrid bool
+	// The border drawn around the whole canvas over everything else, in logical canvas units unaffected by the scene scaling.
+	CanvasBorder CanvasBorder
+	// The tint multiplied into the images of all image blocks, as a theme for the whole render rather than per block.
+	ImageTint ImageTint
+	// The wall-clock limit for laying out and drawing a canvas with Render and RenderWithRegions, which return
+	// ErrRenderTimeout when it is exceeded. The object being measured or drawn at the time still finishes in the
+	// background, but nothing more is laid out, drawn, written onto the scene or reported to OnProgress. Zero means no limit.
+	Timeout time.Duration
+	// The maximum number of objects in the panes of a scene, counting the objects of nested panes too. Scenes with more
+	// are rejected with ErrTooManyObjects before they are laid out. Zero means unlimited.
+	MaxObjects int
+	// The exact logical size of every rendered canvas, e.g. 1200x630 for social cards. When set, the content is scaled
+	// down to fit within it if needed and centered on the background, and MaxCanvasWidth and MaxCanvasHeight only
+	// bound the layout. Content smaller than the fixed size is not scaled up.
+	FixedSize *Size
+	// The font size of image block labels without a font size of their own, clamped like FontSize. Zero uses FontSize.
+	CaptionFontSize float64
+	// The radius of rounded corners cut out of the rendered canvas, in logical units, e.g. for app store screenshots.
+	// The pixels outside the corners are transparent, so the canvas should be encoded as PNG. Zero keeps square corners.
+	CornerRadius float64
+	// The physical resolution written into the encoded PNG (pHYs chunk) and JPEG (JFIF density) output, in dots per
+	// inch, so that print pipelines size the image correctly. It applies to the output pixels, so account for Scale
+	// when setting it. Zero leaves the resolution unspecified.
+	OutputDPI int
+	// The TrueType data of the fonts for the **bold** and *italic* spans of markdown text, see TextBlockOpts.Markdown.
+	// Bold italic spans use the bold font, slanted when there is no italic font. Nil uses the font of the theme, or
+	// synthesizes the style from the regular embedded font without one.
+	BoldFont   []byte
+	ItalicFont []byte
+	// The shared styling defaults for the unset style fields above and for the drawables, see Theme. The theme is
+	// copied by New, so later changes to it don't affect the engine.
+	Theme *Theme
 }
 
+// ErrRenderTimeout is returned when a render takes longer than Config.Timeout.
+var ErrRenderTimeout = errors.New("imacon: render timed out")
+
+// ErrTooManyObjects is returned when a scene has more objects than Config.MaxObjects.
+var ErrTooManyObjects = errors.New("imacon: too many objects")
+
+// ErrOverSizeBudget is returned by Canvas.EncodeUnderSize when even the lowest quality does not fit the byte budget.
+var ErrOverSizeBudget = errors.New("imacon: encoded image exceeds the size budget")
+
+// ContentAlign defines where downscaled content is placed within the canvas.
+type ContentAlign int
+
+const (
+	AlignTopLeft ContentAlign = iota // Place the content at the top-left corner of the canvas
+	AlignCenter                      // Center the content within the canvas
+)
+
 func New(cfg Config) *Engine {
+	if cfg.Theme != nil {
+		theme := *cfg.Theme
+		cfg = theme.apply(cfg)
+		cfg.Theme = &theme
+	}
 	return &Engine{cfg: cfg}
 }
 
+// renderState holds the engine settings that drawables need while a scene is drawn.
+// The zero value matches the default engine behavior.
+type renderState struct {
+	disableAntialias bool
+	debugGrid        bool
+	faces            map[faceKey]font.Face        // The font faces created during the render, by size and style
+	styleFonts       map[fontStyle]*truetype.Font // The fonts of the styled markdown spans, by style
+	regions          map[Tileable]image.Rectangle // The drawn pixel regions of the objects, recorded only when non-nil
+	layer            Tileable                     // The only object drawn when rendering a layer, along with everything nested in it
+	inLayer          bool                         // Whether the layer object is currently being drawn
+	imageTint        ImageTint                    // The tint multiplied into every drawn image block
+	cancelled        atomic.Bool                  // Whether the render was abandoned, so that no further work is done
+	mu               sync.Mutex                   // Held while the render writes its results onto the scene, see commit
+	mainPane         *Pane                        // The main pane of the rendered scene, whose drawing is reported below
+	onProgress       func(done int, total int)    // The progress callback invoked as the objects of the main pane are drawn
+	layout           *CanvasLayout                // The layout recorded as the objects of the main pane are drawn
+	captionFontSize  float64                      // The font size of image block labels, or zero for the engine font size
+	fgColor          color.Color                  // The foreground color the scene is drawn with
+	theme            *Theme                       // The theme of the engine, read by drawables for their unset colors
+	outputScale      float64                      // The output scale factor, which the glyphs are rasterized at, or zero for 1
+}
+
+// newRenderState returns the render state for a context drawn or measured by the engine.
+func (e *Engine) newRenderState() *renderState {
+	state := &renderState{
+		disableAntialias: e.cfg.DisableAntialias,
+		debugGrid:        e.cfg.DebugGrid,
+		imageTint:        e.cfg.ImageTint,
+		faces:            make(map[faceKey]font.Face),
+		theme:            e.cfg.Theme,
+		outputScale:      e.outputScale(),
+	}
+	// the fonts are parsed when the scene is measured, which reports the error
+	state.styleFonts, _ = e.parseStyleFonts()
+	if e.cfg.CaptionFontSize > 0 {
+		state.captionFontSize = clampFontSize(e.cfg.CaptionFontSize, e.cfg.MinFontSize, e.cfg.MaxFontSize)
+	}
+	return state
+}
+
+// commit runs fn, which writes the results of the render onto the scene or reports them to the caller, unless the render
+// was abandoned. The state's lock is held meanwhile, so that nothing is written once cancel has returned.
+func (s *renderState) commit(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cancelled.Load() {
+		fn()
+	}
+}
+
+// cancel abandons the render, waiting for a commit in progress to finish.
+func (s *renderState) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelled.Store(true)
+}
+
+// renderStates maps each context being rendered by an engine to its render state.
+var renderStates sync.Map
+
+// stateOf returns the render state of the context, or the default state if the context isn't being rendered by an engine.
+func stateOf(ctx *gg.Context) *renderState {
+	if state, ok := renderStates.Load(ctx); ok {
+		return state.(*renderState)
+	}
+	return &renderState{faces: make(map[faceKey]font.Face), fgColor: color.Black}
+}
+
+// fillRect fills a rectangle in the current transform, snapping its edges to whole device pixels when anti-aliasing is disabled.
+func fillRect(ctx *gg.Context, x float64, y float64, w float64, h float64) {
+	if !stateOf(ctx).disableAntialias {
+		ctx.DrawRectangle(x, y, w, h)
+		ctx.Fill()
+		return
+	}
+	fillSnappedRect(ctx, x, y, w, h)
+}
+
+// withClip calls fn with the context clipped to the current path, within any clip already set, and then restores the
+// context to its state before the call, like Push and Pop. gg keeps the clip mask across Pop and has no getter for it,
+// so the context is saved by value instead, which restores the caller's clip rather than resetting it.
+func withClip(ctx *gg.Context, fn func()) {
+	saved := *ctx
+	ctx.Clip()
+	fn()
+	*ctx = saved
+	ctx.ClearPath()
+}
+
+// fillSnappedRect fills a rectangle in the current transform with its edges snapped to whole device pixels, so that thin
+// rules come out crisp instead of blurred across two rows when a fractional offset or scale lands them between pixels.
+func fillSnappedRect(ctx *gg.Context, x float64, y float64, w float64, h float64) {
+	x0, y0 := ctx.TransformPoint(x, y)
+	x1, y1 := ctx.TransformPoint(x+w, y+h)
+	left, top := math.Round(math.Min(x0, x1)), math.Round(math.Min(y0, y1))
+	width := math.Max(1, math.Round(math.Abs(x1-x0)))
+	height := math.Max(1, math.Round(math.Abs(y1-y0)))
+	ctx.Push()
+	ctx.Identity()
+	ctx.DrawRectangle(left, top, width, height)
+	ctx.Fill()
+	ctx.Pop()
+}
+
+// snapStroke returns the point moved so that a horizontal or vertical stroke of the given width through it covers whole
+// device pixels, e.g. through the center of a pixel for a 1 pixel line. gg doesn't scale line widths, so the width is in
+// device pixels, while the point is in the current transform, which is assumed not to rotate.
+func snapStroke(ctx *gg.Context, x float64, y float64, width float64) (float64, float64) {
+	ox, oy := ctx.TransformPoint(0, 0)
+	ux, _ := ctx.TransformPoint(1, 0)
+	_, uy := ctx.TransformPoint(0, 1)
+	dx, dy := ctx.TransformPoint(x, y)
+	// snap moves a device coordinate so that the stroke starts on a pixel boundary, returning the move in user units
+	snap := func(v float64, scale float64) float64 {
+		if scale == 0 {
+			return 0
+		}
+		start := v - width/2
+		return (math.Round(start) - start) / scale
+	}
+	return x + snap(dx, ux-ox), y + snap(dy, uy-oy)
+}
+
+// drawImage draws the image at the origin of the current transform, using nearest-neighbor sampling when anti-aliasing is disabled.
+// The nearest-neighbor path ignores any clip mask set on the context.
+func drawImage(ctx *gg.Context, img image.Image) {
+	dst, ok := ctx.Image().(draw.Image)
+	if !stateOf(ctx).disableAntialias || !ok {
+		ctx.DrawImageAnchored(img, 0, 0, 0, 0)
+		return
+	}
+	x0, y0 := ctx.TransformPoint(0, 0)
+	x1, y1 := ctx.TransformPoint(1, 0)
+	x2, y2 := ctx.TransformPoint(0, 1)
+	s2d := f64.Aff3{x1 - x0, x2 - x0, x0, y1 - y0, y2 - y0, y0}
+	draw.NearestNeighbor.Transform(dst, s2d, img, img.Bounds(), draw.Over, nil)
+}
+
+// deviceRect returns the device pixel rectangle covered by the box of the given size at the origin of the current transform.
+func deviceRect(ctx *gg.Context, w float64, h float64) image.Rectangle {
+	x0, y0 := ctx.TransformPoint(0, 0)
+	x1, y1 := ctx.TransformPoint(w, h)
+	return image.Rect(
+		int(math.Floor(math.Min(x0, x1))), int(math.Floor(math.Min(y0, y1))),
+		int(math.Ceil(math.Max(x0, x1))), int(math.Ceil(math.Max(y0, y1))),
+	)
+}
+
+// recordRegion records the region of an object drawn in the box of the given size at the origin of the current transform,
+// if the render is collecting regions.
+func recordRegion(ctx *gg.Context, obj Tileable, w float64, h float64) {
+	if regions := stateOf(ctx).regions; regions != nil {
+		regions[obj] = deviceRect(ctx, w, h)
+	}
+}
+
+// CanvasLayout describes how the main pane of a scene was tiled onto the canvas.
+type CanvasLayout struct {
+	Shape  Shape               // The shape the main pane was drawn with, holding the original objects of the pane
+	Bounds [][]image.Rectangle // The pixel rectangle of each object on the canvas, indexed like Shape.Columns[i].Objects[j]
+}
+
 // Canvas represents the rendered image canvas.
 type Canvas struct {
-	Width  int         // The width of the canvas in pixels.
-	Height int         // The height of the canvas in pixels.
-	Raw    image.Image // The raw image data of the canvas.
+	Width  int           // The width of the canvas in pixels.
+	Height int           // The height of the canvas in pixels.
+	Raw    image.Image   // The raw image data of the canvas.
+	Layout *CanvasLayout // How the main pane was tiled onto the canvas, for debugging and introspection.
+	Stats  RenderStats   // How the canvas was rendered. It is zero for canvases made with Thumbnail or Crop.
+
+	jpegQuality int         // The default JPEG quality from the engine config
+	jpegMatte   color.Color // The color transparent pixels are composited over for JPEG, nil for white
+	meta        metadata    // The descriptive text and resolution embedded into the encoded output
 }
 
 // ToJpeg encodes the canvas image to JPEG format and writes it to the provided writer.
+// If options is nil, the engine's configured DefaultJpegQuality is used. Transparent pixels are composited over the
+// configured JpegMatte color.
 func (c *Canvas) ToJpeg(writer io.Writer, options *jpeg.Options) error {
-	if err := jpeg.Encode(writer, c.Raw, options); err != nil {
+	if options == nil && c.jpegQuality != 0 {
+		options = &jpeg.Options{Quality: c.jpegQuality}
+	}
+	img := c.jpegImage()
+	if c.meta.empty() {
+		if err := jpeg.Encode(writer, img, options); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// the jpeg package has no metadata support, so the JFIF and EXIF segments are spliced into the encoded stream
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, options); err != nil {
 		return err
 	}
-	return nil
+	segments, err := c.meta.jpegSegments()
+	if err != nil {
+		return err
+	}
+	return writeJpegWithSegments(writer, buf.Bytes(), segments)
+}
+
+// ToJpegWithOptions encodes the canvas image to JPEG format like ToJpeg, additionally choosing the chroma subsampling.
+// Subsampling444 avoids the color fringes of 4:2:0 around sharp text and lines, at the cost of a larger file.
+// If options is nil or has a zero Quality, the engine's configured DefaultJpegQuality is used.
+func (c *Canvas) ToJpegWithOptions(writer io.Writer, options *JpegOptions) error {
+	if options == nil {
+		return c.ToJpeg(writer, nil)
+	}
+	quality := options.Quality
+	if quality == 0 {
+		quality = c.jpegQuality
+	}
+	switch options.Subsampling {
+	case Subsampling420:
+		if quality == 0 {
+			return c.ToJpeg(writer, nil)
+		}
+		return c.ToJpeg(writer, &jpeg.Options{Quality: quality})
+	case Subsampling444:
+	default:
+		return fmt.Errorf("imacon: unknown chroma subsampling %d", options.Subsampling)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJpeg444(&buf, c.jpegImage(), quality); err != nil {
+		return err
+	}
+	if c.meta.empty() {
+		_, err := writer.Write(buf.Bytes())
+		return err
+	}
+	segments, err := c.meta.jpegSegments()
+	if err != nil {
+		return err
+	}
+	return writeJpegWithSegments(writer, buf.Bytes(), segments)
+}
+
+// jpegImage returns the canvas image to encode as JPEG, composited over the matte color if it has transparent pixels,
+// as JPEG would otherwise turn them black.
+func (c *Canvas) jpegImage() image.Image {
+	if opaque, ok := c.Raw.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return c.Raw
+	}
+	matte := c.jpegMatte
+	if matte == nil {
+		matte = color.White
+	}
+	bounds := c.Raw.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(matte), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, c.Raw, bounds.Min, draw.Over)
+	return dst
 }
 
 // ToPng encodes the canvas image to PNG format and writes it to the provided writer.
 func (c *Canvas) ToPng(writer io.Writer) error {
-	if err := png.Encode(writer, c.Raw); err != nil {
+	return c.ToPngWithLevel(writer, png.DefaultCompression)
+}
+
+// ToPngWithLevel encodes the canvas image to PNG format with the given compression level and writes it to the provided writer.
+func (c *Canvas) ToPngWithLevel(writer io.Writer, level png.CompressionLevel) error {
+	encoder := &png.Encoder{CompressionLevel: level}
+	if c.meta.empty() {
+		if err := encoder.Encode(writer, c.Raw); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// the png package has no metadata support, so the pHYs and text chunks are spliced into the encoded stream
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, c.Raw); err != nil {
 		return err
 	}
-	return nil
+	return writePngWithChunks(writer, buf.Bytes(), c.meta.pngChunks())
 }
 
-// Render generates a canvas by rendering the provided scene according to the engine's configuration.
-func (e *Engine) Render(scene *Scene) (*Canvas, error) {
+// Save encodes the canvas into the file at path with the default options, in the format given by its extension:
+// .png, or .jpg and .jpeg for JPEG. WebP output isn't supported, as there is no WebP encoder available.
+func (c *Canvas) Save(path string) (err error) {
+	var encode func(io.Writer) error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		encode = c.ToPng
+	case ".jpg", ".jpeg":
+		encode = func(w io.Writer) error { return c.ToJpeg(w, nil) }
+	case ".webp":
+		return fmt.Errorf("imacon: saving as WebP is not supported")
+	default:
+		return fmt.Errorf("imacon: unknown image format for extension %q", ext)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return encode(file)
+}
+
+// EncodeUnderSize encodes the canvas in the given format at the best quality whose output fits in maxBytes, e.g. for
+// platforms with upload size limits. JPEG quality is binary-searched from 1 to 100, while PNG, being lossless, is
+// encoded once. ErrOverSizeBudget is returned if even the lowest quality exceeds the budget.
+func (c *Canvas) EncodeUnderSize(format Format, maxBytes int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatPNG:
+		if err := c.ToPng(&buf); err != nil {
+			return nil, err
+		}
+		if buf.Len() > maxBytes {
+			return nil, fmt.Errorf("%w: PNG is %d bytes, over the budget of %d", ErrOverSizeBudget, buf.Len(), maxBytes)
+		}
+		return buf.Bytes(), nil
+	case FormatJPEG:
+	default:
+		return nil, fmt.Errorf("imacon: unsupported format %d", format)
+	}
+
+	// the size grows with the quality, so the best fit is the highest quality under the budget
+	var best []byte
+	smallest := 0
+	low, high := 1, 100
+	for low <= high {
+		quality := (low + high) / 2
+		buf.Reset()
+		if err := c.ToJpeg(&buf, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxBytes {
+			best = bytes.Clone(buf.Bytes())
+			low = quality + 1
+		} else {
+			smallest = buf.Len()
+			high = quality - 1
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: JPEG at quality 1 is %d bytes, over the budget of %d", ErrOverSizeBudget, smallest, maxBytes)
+	}
+	return best, nil
+}
+
+// Thumbnail returns a new canvas scaled down to fit within maxW x maxH, preserving the aspect ratio.
+// The canvas is never scaled up, and the original canvas is left untouched.
+func (c *Canvas) Thumbnail(maxW int, maxH int) *Canvas {
+	scale := math.Min(1, math.Min(float64(maxW)/float64(c.Width), float64(maxH)/float64(c.Height)))
+	width := max(1, int(math.Round(float64(c.Width)*scale)))
+	height := max(1, int(math.Round(float64(c.Height)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), c.Raw, c.Raw.Bounds(), draw.Src, nil)
+	return &Canvas{
+		Width:       width,
+		Height:      height,
+		Raw:         dst,
+		jpegQuality: c.jpegQuality,
+		jpegMatte:   c.jpegMatte,
+		meta:        c.meta,
+	}
+}
+
+// Crop returns a new canvas trimmed to the bounding box of the pixels that differ from bgColor, e.g. to remove the
+// outer padding around the content. A nil bgColor trims fully transparent pixels. If every pixel matches the
+// background, the original canvas is returned.
+func (c *Canvas) Crop(bgColor color.Color) *Canvas {
+	if bgColor == nil {
+		bgColor = color.Transparent
+	}
+	br, bg, bb, ba := bgColor.RGBA()
+	bounds := c.Raw.Bounds()
+	content := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, g, b, a := c.Raw.At(x, y).RGBA(); r != br || g != bg || b != bb || a != ba {
+				content = content.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if content.Empty() {
+		return c
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, content.Dx(), content.Dy()))
+	draw.Draw(dst, dst.Bounds(), c.Raw, content.Min, draw.Src)
+	return &Canvas{
+		Width:       content.Dx(),
+		Height:      content.Dy(),
+		Raw:         dst,
+		jpegQuality: c.jpegQuality,
+		jpegMatte:   c.jpegMatte,
+		meta:        c.meta,
+	}
+}
+
+// fontFace loads the embedded font as a face of the configured font size.
+func (e *Engine) fontFace() (font.Face, error) {
+	return newFontFace(e.fontSize())
+}
+
+// fontSize returns the configured font size clamped into the allowed range.
+func (e *Engine) fontSize() float64 {
+	fontSize := e.cfg.FontSize
+	if fontSize == 0 {
+		fontSize = 12
+	}
+	return clampFontSize(fontSize, e.cfg.MinFontSize, e.cfg.MaxFontSize)
+}
+
+var (
+	parsedFont    *truetype.Font
+	parsedFontErr error
+	parseFontOnce sync.Once
+)
+
+// newFontFace returns a new face of the embedded font at the given size. The font is only parsed once,
+// but each face has its own glyph cache and must not be shared between concurrent renders.
+func newFontFace(size float64) (font.Face, error) {
+	parseFontOnce.Do(func() {
+		fontData, err := embeddedFont.ReadFile("assets/fonts/JetBrainsMono-Regular.ttf")
+		if err != nil {
+			parsedFontErr = fmt.Errorf("failed to read embedded font: %w", err)
+			return
+		}
+		parsedFont, err = truetype.Parse(fontData)
+		if err != nil {
+			parsedFontErr = fmt.Errorf("failed to parse font: %w", err)
+		}
+	})
+	if parsedFontErr != nil {
+		return nil, parsedFontErr
+	}
+
+	return truetype.NewFace(parsedFont, &truetype.Options{
+		Size: size,
+		DPI:  72,
+	}), nil
+}
+
+// faceKey identifies the font faces created during a render.
+type faceKey struct {
+	size  float64
+	style fontStyle
+}
+
+// fontFaceOf returns a face of the embedded font at the given size for drawing onto the context.
+// Faces are reused for the duration of the render that owns the context.
+func fontFaceOf(ctx *gg.Context, size float64) (font.Face, error) {
+	state, ok := renderStates.Load(ctx)
+	if !ok {
+		return newFontFace(size)
+	}
+	faces := state.(*renderState).faces
+	if face, ok := faces[faceKey{size: size}]; ok {
+		return face, nil
+	}
+	face, err := newFontFace(size)
+	if err != nil {
+		return nil, err
+	}
+	faces[faceKey{size: size}] = face
+	return face, nil
+}
+
+// styledFaceOf returns a face of the engine's font for the style at the given size, reused like fontFaceOf, along
+// with the style the face actually has. A bold-italic span falls back to the bold font, and the style is 0 if the
+// engine has no font for it, including for contexts not rendered by an engine.
+func styledFaceOf(ctx *gg.Context, size float64, style fontStyle) (font.Face, fontStyle) {
+	state := stateOf(ctx)
+	f, ok := state.styleFonts[style]
+	if !ok && style&styleBold != 0 {
+		style = styleBold
+		f, ok = state.styleFonts[style]
+	}
+	if !ok {
+		return nil, 0
+	}
+	key := faceKey{size: size, style: style}
+	if face, ok := state.faces[key]; ok {
+		return face, style
+	}
+	face := truetype.NewFace(f, &truetype.Options{Size: size, DPI: 72})
+	state.faces[key] = face
+	return face, style
+}
+
+// parseStyleFonts parses the configured fonts of the styled markdown spans, once for the engine.
+func (e *Engine) parseStyleFonts() (map[fontStyle]*truetype.Font, error) {
+	e.styleFontsOnce.Do(func() {
+		e.styleFonts = make(map[fontStyle]*truetype.Font)
+		for _, variant := range []struct {
+			name  string
+			data  []byte
+			style fontStyle
+		}{{"bold", e.cfg.BoldFont, styleBold}, {"italic", e.cfg.ItalicFont, styleItalic}} {
+			if variant.data == nil {
+				continue
+			}
+			f, err := truetype.Parse(variant.data)
+			if err != nil {
+				e.styleFontsErr = fmt.Errorf("imacon: failed to parse %s font: %w", variant.name, err)
+				return
+			}
+			e.styleFonts[variant.style] = f
+		}
+	})
+	return e.styleFonts, e.styleFontsErr
+}
+
+// measure lays out the scene and returns its canvas size before clamping.
+func (e *Engine) measure(scene *Scene, outerPad float64, state *renderState) (int, int, error) {
+	if scene == nil || (scene.Main == nil && len(scene.Panes) == 0) {
+		return 0, 0, fmt.Errorf("imacon: scene has no Main pane")
+	}
+	if scene.Main != nil && len(scene.Panes) > 0 {
+		return 0, 0, fmt.Errorf("imacon: scene has both a Main pane and Panes")
+	}
+	if i := slices.Index(scene.Panes, nil); i >= 0 {
+		return 0, 0, fmt.Errorf("imacon: scene pane %d is nil", i)
+	}
+	if e.cfg.Scale < 0 {
+		return 0, 0, fmt.Errorf("imacon: scale must not be negative, got %v", e.cfg.Scale)
+	}
+	if e.cfg.MaxCanvasWidth < 0 || e.cfg.MaxCanvasHeight < 0 {
+		return 0, 0, fmt.Errorf("imacon: max canvas size must not be negative, got %dx%d", e.cfg.MaxCanvasWidth, e.cfg.MaxCanvasHeight)
+	}
+	if fixed := e.cfg.FixedSize; fixed != nil && (fixed.Width < 1 || fixed.Height < 1) {
+		return 0, 0, fmt.Errorf("imacon: fixed canvas size must be at least 1x1, got %vx%v", fixed.Width, fixed.Height)
+	}
+	if e.cfg.MaxObjects > 0 {
+		if count := scene.countObjects(); count > e.cfg.MaxObjects {
+			return 0, 0, fmt.Errorf("%w: %d objects exceed the limit of %d", ErrTooManyObjects, count, e.cfg.MaxObjects)
+		}
+	}
+
+	// limit the main pane's layout to the space available within the max canvas width
+	if e.cfg.MaxCanvasWidth > 0 {
+		maxWidth := float64(e.cfg.MaxCanvasWidth) - outerPad*2
+		state.commit(func() {
+			for _, pane := range []*Pane{scene.Main, scene.Header, scene.Footer} {
+				if pane != nil {
+					pane.maxWidth = maxWidth
+				}
+			}
+			if row, ok := scene.body().(*paneRow); ok {
+				row.setMaxWidth(maxWidth)
+			}
+		})
+	}
+
+	if _, err := e.parseStyleFonts(); err != nil {
+		return 0, 0, err
+	}
+	ctx, err := e.measureContext()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer e.measureCtxs.Put(ctx)
+	renderStates.Store(ctx, state)
+	defer renderStates.Delete(ctx)
+	width, height := scene.canvasSize(ctx, outerPad)
+	return width, height, nil
+}
+
+// measureContext returns a context for measuring scenes, set up with the configured font face.
+// It is taken from the engine's pool, so concurrent measurements each get their own context and face.
+func (e *Engine) measureContext() (*gg.Context, error) {
+	if ctx, ok := e.measureCtxs.Get().(*gg.Context); ok {
+		ctx.Identity()
+		return ctx, nil
+	}
+	fontFace, err := e.fontFace()
+	if err != nil {
+		return nil, err
+	}
+	// nothing is drawn while measuring, so the smallest context will do
+	ctx := gg.NewContext(1, 1)
+	ctx.SetFontFace(fontFace)
+	return ctx, nil
+}
+
+// Measure computes the canvas size of the scene without drawing it.
+// It returns the dimensions before clamping to the max canvas size, and whether Render would clamp them.
+func (e *Engine) Measure(scene *Scene) (int, int, bool, error) {
+	width, height, err := e.measure(scene, e.outerPad(), e.newRenderState())
+	if err != nil {
+		return 0, 0, false, err
+	}
+	clamped := e.clampsWidth(width) || e.clampsHeight(height)
+	return e.scaled(width), e.scaled(height), clamped, nil
+}
+
+// outerPad returns the padding around the content of the canvas, from the theme or DefaultOuterPad.
+func (e *Engine) outerPad() float64 {
+	if e.cfg.Theme != nil && e.cfg.Theme.OuterPad > 0 {
+		return e.cfg.Theme.OuterPad
+	}
+	return DefaultOuterPad
+}
+
+// metadata returns the metadata embedded into the encoded output of the scene.
+func (e *Engine) metadata(scene *Scene) metadata {
+	return metadata{Title: scene.Title, Description: scene.Description, Created: scene.Created, DPI: e.cfg.OutputDPI}
+}
+
+// clampsWidth reports whether the logical canvas width exceeds the max canvas width, if bounded.
+func (e *Engine) clampsWidth(width int) bool {
+	return e.cfg.MaxCanvasWidth > 0 && width > e.cfg.MaxCanvasWidth
+}
+
+// clampsHeight reports whether the logical canvas height exceeds the max canvas height, if bounded.
+func (e *Engine) clampsHeight(height int) bool {
+	return e.cfg.MaxCanvasHeight > 0 && height > e.cfg.MaxCanvasHeight
+}
+
+// outputScale returns the configured output scale factor, defaulting to 1.
+func (e *Engine) outputScale() float64 {
+	if e.cfg.Scale == 0 {
+		return 1
+	}
+	return e.cfg.Scale
+}
+
+// scaled converts a logical dimension into output pixels.
+func (e *Engine) scaled(v int) int {
+	return int(math.Round(float64(v) * e.outputScale()))
+}
+
+// renderPlan holds a measured scene that is ready to be drawn.
+type renderPlan struct {
+	bgColor  color.Color
+	fgColor  color.Color
+	fontFace font.Face
+	outerPad float64
+	width    int     // The logical canvas width after clamping
+	height   int     // The logical canvas height after clamping
+	measured Size    // The logical size of the content before clamping
+	scale    float64 // The scale factor used to fit the scene within the max canvas size
+	contentX float64 // The horizontal offset of the scaled content within the canvas, in logical units
+	contentY float64 // The vertical offset of the scaled content within the canvas, in logical units
+}
+
+// plan lays out the scene and computes the canvas size and scale factor according to the engine's configuration.
+func (e *Engine) plan(scene *Scene, state *renderState) (*renderPlan, error) {
 
 	// define config values
 	bgColor := e.cfg.BgColor
@@ -97,59 +827,219 @@ func (e *Engine) Render(scene *Scene) (*Canvas, error) {
 	fgColor := e.cfg.FgColor
 	if fgColor == nil {
 		fgColor = color.Black
+		if e.cfg.AutoContrastText {
+			fgColor = contrastColor(bgColor)
+		}
 	}
-	fontSize := e.cfg.FontSize
-	if fontSize == 0 {
-		fontSize = 12
-	}
-	outerPad := DefaultOuterPad
+	outerPad := e.outerPad()
 	scale := 1.0
 
-	fontData, err := embeddedFont.ReadFile("assets/fonts/JetBrainsMono-Regular.ttf")
+	width, height, err := e.measure(scene, outerPad, state)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read embedded font: %w", err)
+		return nil, err
+	}
+	// the drawing gets a face of its own, as faces aren't safe for concurrent use with the pooled measurement contexts
+	fontFace, err := e.fontFace()
+	if err != nil {
+		return nil, err
+	}
+
+	// measure the scale factor used to fit within max canvas size
+	contentW, contentH := float64(width), float64(height)
+	if fixed := e.cfg.FixedSize; fixed != nil {
+		width, height = int(math.Round(fixed.Width)), int(math.Round(fixed.Height))
+		scale = math.Min(1, math.Min(float64(width)/contentW, float64(height)/contentH))
+	} else {
+		if e.clampsWidth(width) {
+			scale = float64(e.cfg.MaxCanvasWidth) / float64(width)
+			width = e.cfg.MaxCanvasWidth
+		}
+		if e.clampsHeight(height) {
+			scale = math.Min(scale, float64(e.cfg.MaxCanvasHeight)/float64(height))
+			height = e.cfg.MaxCanvasHeight
+		}
+	}
+
+	contentX, contentY := 0.0, 0.0
+	// fixed size output is letterboxed, with the content centered in both dimensions
+	if e.cfg.ContentAlign == AlignCenter || e.cfg.FixedSize != nil {
+		contentX = (float64(width) - contentW*scale) / 2
+		contentY = (float64(height) - contentH*scale) / 2
 	}
 
-	f, err := truetype.Parse(fontData)
+	return &renderPlan{
+		bgColor:  bgColor,
+		fgColor:  fgColor,
+		fontFace: fontFace,
+		outerPad: outerPad,
+		width:    width,
+		height:   height,
+		measured: Size{Width: contentW, Height: contentH},
+		scale:    scale,
+		contentX: contentX,
+		contentY: contentY,
+	}, nil
+}
+
+// draw renders the planned scene onto the context with the given render state. The context covers the output canvas
+// rows starting at offsetY, which allows the canvas to be drawn in horizontal strips.
+func (e *Engine) draw(ctx *gg.Context, scene *Scene, plan *renderPlan, offsetY float64, state *renderState) {
+	renderStates.Store(ctx, state)
+	defer renderStates.Delete(ctx)
+
+	outputScale := e.outputScale()
+	ctx.Identity()
+	ctx.ResetClip()
+	ctx.SetColor(plan.bgColor)
+	ctx.Clear()
+	ctx.Translate(0, -offsetY)
+	ctx.Scale(outputScale, outputScale)
+	e.drawPlanned(ctx, scene, plan, state)
+}
+
+// drawPlanned draws the planned scene onto the context, whose transform is expected to be in logical canvas coordinates.
+func (e *Engine) drawPlanned(ctx *gg.Context, scene *Scene, plan *renderPlan, state *renderState) {
+	state.fgColor = plan.fgColor
+	ctx.SetColor(plan.fgColor)
+	ctx.SetFontFace(plan.fontFace)
+
+	ctx.Push()
+	ctx.Translate(plan.contentX, plan.contentY)
+	ctx.Scale(plan.scale, plan.scale)
+	ctx.Translate(plan.outerPad, plan.outerPad)
+	scene.drawContent(ctx, float64(plan.width), float64(plan.height))
+	ctx.Pop()
+
+	// overlays are positioned in logical canvas coordinates, unaffected by the clamping scale and padding
+	if state.layer != nil {
+		return
+	}
+	for _, overlay := range scene.Overlays {
+		overlay.Draw(ctx, float64(plan.width), float64(plan.height))
+	}
+	e.cfg.CanvasBorder.draw(ctx, float64(plan.width), float64(plan.height))
+}
+
+// DrawOnto lays out the scene and draws it onto an existing context with its top-left corner at (x, y), e.g. for
+// compositing imacon output into a larger design. Any draw.Image can be drawn onto by wrapping it with
+// gg.NewContextForRGBA or gg.NewContextForImage.
+//
+// The scene's canvas area is filled with the background color first, and the context's current transform and clip
+// are respected and left unchanged. Progress callbacks are not invoked.
+func (e *Engine) DrawOnto(ctx *gg.Context, scene *Scene, x float64, y float64) error {
+	state := e.newRenderState()
+	plan, err := e.plan(scene, state)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse font: %w", err)
+		return err
 	}
+	renderStates.Store(ctx, state)
+	defer renderStates.Delete(ctx)
 
-	fontFace := truetype.NewFace(f, &truetype.Options{
-		Size: fontSize,
-		DPI:  72,
-	})
+	ctx.Push()
+	defer ctx.Pop()
+	outputScale := e.outputScale()
+	ctx.Translate(x, y)
+	ctx.Scale(outputScale, outputScale)
+	ctx.SetColor(plan.bgColor)
+	fillRect(ctx, 0, 0, float64(plan.width), float64(plan.height))
+	e.drawPlanned(ctx, scene, plan, state)
+	return nil
+}
 
-	// temp canvas to measure canvas size
-	tempCtx := gg.NewContext(100, 100)
-	tempCtx.SetFontFace(fontFace)
-	width, height := scene.canvasSize(tempCtx, outerPad)
+// Render generates a canvas by rendering the provided scene according to the engine's configuration.
+func (e *Engine) Render(scene *Scene) (*Canvas, error) {
+	return e.render(scene, e.newRenderState())
+}
 
-	// measure the scale factor used to fit within max canvas size
-	if width > e.cfg.MaxCanvasWidth {
-		scale = float64(e.cfg.MaxCanvasWidth) / float64(width)
-		width = e.cfg.MaxCanvasWidth
+// RenderResult holds a rendered canvas along with the pixel region each object was drawn in.
+type RenderResult struct {
+	Canvas  *Canvas
+	Regions map[Tileable]image.Rectangle // The pixel rectangle each drawn object occupies on the canvas, including nested and overlay objects
+}
+
+// RenderWithRegions renders the scene like Render, and also records the pixel region of every object drawn through
+// a pane or as an overlay, e.g. for building clickable image maps.
+func (e *Engine) RenderWithRegions(scene *Scene) (*RenderResult, error) {
+	state := e.newRenderState()
+	state.regions = make(map[Tileable]image.Rectangle)
+	canvas, err := e.render(scene, state)
+	if err != nil {
+		return nil, err
 	}
-	if height > e.cfg.MaxCanvasHeight {
-		scale = math.Min(scale, float64(e.cfg.MaxCanvasHeight)/float64(height))
-		height = e.cfg.MaxCanvasHeight
+	return &RenderResult{Canvas: canvas, Regions: state.regions}, nil
+}
+
+// render generates a canvas by rendering the scene with the given render state, within the configured timeout.
+func (e *Engine) render(scene *Scene, state *renderState) (*Canvas, error) {
+	if e.cfg.Timeout <= 0 {
+		return e.renderCanvas(scene, state)
+	}
+	type result struct {
+		canvas *Canvas
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		canvas, err := e.renderCanvas(scene, state)
+		done <- result{canvas, err}
+	}()
+	timer := time.NewTimer(e.cfg.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.canvas, r.err
+	case <-timer.C:
+		// the object being measured or drawn can't be interrupted, but the render stops before the next one, writes no
+		// more results onto the scene and its canvas is dropped
+		state.cancel()
+		return nil, ErrRenderTimeout
 	}
+}
 
-	ctx := gg.NewContext(width, height)
-	ctx.SetColor(bgColor)
-	ctx.Clear()
-	ctx.ScaleAbout(scale, scale, 0, 0)
-	ctx.SetColor(fgColor)
+// renderCanvas generates a canvas by rendering the scene with the given render state.
+func (e *Engine) renderCanvas(scene *Scene, state *renderState) (*Canvas, error) {
+	start := time.Now()
+	plan, err := e.plan(scene, state)
+	if err != nil {
+		return nil, err
+	}
+	layoutTime := time.Since(start)
+	start = time.Now()
 
-	ctx.SetFontFace(fontFace)
-	ctx.Translate(outerPad, outerPad)
+	ctx := gg.NewContext(e.scaled(plan.width), e.scaled(plan.height))
+	if scene.Main != nil {
+		state.mainPane = scene.Main
+		state.onProgress = e.cfg.OnProgress
+		state.layout = &CanvasLayout{}
+	}
+	e.draw(ctx, scene, plan, 0, state)
+	if state.cancelled.Load() {
+		return nil, ErrRenderTimeout
+	}
+	if e.cfg.CornerRadius > 0 {
+		roundCorners(ctx.Image().(*image.RGBA), 0, ctx.Width(), ctx.Height(), e.cfg.CornerRadius*e.outputScale())
+	}
 
-	pane := scene.Main
-	pane.Draw(ctx, float64(width), float64(height))
+	stats := RenderStats{
+		LayoutTime:     layoutTime,
+		DrawTime:       time.Since(start),
+		Objects:        scene.countObjects(),
+		Columns:        scene.countColumns(),
+		MeasuredWidth:  int(plan.measured.Width),
+		MeasuredHeight: int(plan.measured.Height),
+		Width:          plan.width,
+		Height:         plan.height,
+		Clamped:        plan.scale < 1,
+	}
 	canvas := &Canvas{
-		Width:  width,
-		Height: height,
-		Raw:    ctx.Image(),
+		Width:       ctx.Width(),
+		Height:      ctx.Height(),
+		Raw:         ctx.Image(),
+		Layout:      state.layout,
+		Stats:       stats,
+		jpegQuality: e.cfg.DefaultJpegQuality,
+		jpegMatte:   e.cfg.JpegMatte,
+		meta:        e.metadata(scene),
 	}
 
 	return canvas, nil
@@ -157,26 +1047,162 @@ func (e *Engine) Render(scene *Scene) (*Canvas, error) {
 
 // Scene represents the overall image composition, containing panes and their layout properties.
 type Scene struct {
-	Main *Pane // The main pane that holds all the objects to be rendered.
+	Main   *Pane // The main pane that holds all the objects to be rendered.
+	Header *Pane // An optional band laid out above the main pane, independent of its column layout.
+	Footer *Pane // An optional band laid out below the main pane, independent of its column layout.
+	// The top-level panes laid out side by side in place of the main pane, each with its own layout parameters,
+	// e.g. a narrow sidebar next to a wide content pane. Only one of Main and Panes may be set.
+	Panes []*Pane
+	// The relative width of each of the Panes, e.g. {1, 3} for a sidebar a third as wide as the content.
+	// Missing or non-positive widths default to 1. The slots are sized so that every pane fits within its own.
+	PaneWidths []float64
+	// An optional rule drawn across the content width between the main pane and the footer.
+	FooterDivider *Divider
+	Title         string               // The title embedded as metadata in the encoded output, if set.
+	Description   string               // The description (alt text) embedded as metadata in the encoded output, if set.
+	Created       time.Time            // The creation time embedded as metadata in the encoded output, if set.
+	Overlays      []PositionedDrawable // Objects drawn over the main pane at absolute canvas positions, outside of the layout.
 	// Expect there are some layout properties here in the future
 	// ...
 }
 
+// PositionedDrawable places a drawable at an absolute position in canvas coordinates, outside of the tiling flow.
+type PositionedDrawable struct {
+	Object  Drawable // The object to draw
+	X       float64  // The x position of the anchor point in canvas pixels
+	Y       float64  // The y position of the anchor point in canvas pixels
+	AnchorX float64  // The horizontal anchor relative to the object width, e.g. 0.5 for its center. Only applies to Tileable objects.
+	AnchorY float64  // The vertical anchor relative to the object height, e.g. 0.5 for its center. Only applies to Tileable objects.
+}
+
+// Draw the overlay onto the given context, whose transform is expected to be in canvas coordinates.
+func (o PositionedDrawable) Draw(ctx *gg.Context, cw float64, ch float64) {
+	w, h := cw-o.X, ch-o.Y
+	if t, ok := o.Object.(Tileable); ok {
+		w, h = t.IntrinsicSize(ctx, 0, 0)
+	}
+	ctx.Push()
+	ctx.Translate(o.X-o.AnchorX*w, o.Y-o.AnchorY*h)
+	o.Object.Draw(ctx, w, h)
+	if t, ok := o.Object.(Tileable); ok {
+		recordRegion(ctx, t, w, h)
+	}
+	ctx.Pop()
+}
+
 func (s *Scene) canvasSize(ctx *gg.Context, outerPad float64) (int, int) {
 	if outerPad == 0 {
 		outerPad = DefaultOuterPad
 	}
-	w, h := s.Main.IntrinsicSize(ctx, 0, 0)
+	w, h := s.contentSize(ctx)
 	return int(w + outerPad*2), int(h + outerPad*2)
 }
 
+// contentSize returns the size of the header band, main pane and footer band stacked together, without the outer padding.
+func (s *Scene) contentSize(ctx *gg.Context) (float64, float64) {
+	w, h := s.body().IntrinsicSize(ctx, 0, 0)
+	if s.Header != nil {
+		hw, hh := s.Header.IntrinsicSize(ctx, 0, 0)
+		w = math.Max(w, hw)
+		h += hh + DefaultBandPad
+	}
+	if s.Footer != nil {
+		fw, fh := s.Footer.IntrinsicSize(ctx, 0, 0)
+		w = math.Max(w, fw)
+		h += DefaultBandPad + fh
+		if s.FooterDivider != nil {
+			h += s.FooterDivider.Thickness + DefaultBandPad
+		}
+	}
+	return w, h
+}
+
+// Draw the header band, the main pane and the footer band of the scene, stacked top to bottom from the origin.
+func (s *Scene) drawContent(ctx *gg.Context, cw float64, ch float64) {
+	ctx.Push()
+	if s.Header != nil {
+		_, hh := s.Header.IntrinsicSize(ctx, 0, 0)
+		s.Header.Draw(ctx, cw, hh)
+		ctx.Translate(0, hh+DefaultBandPad)
+	}
+	body := s.body()
+	body.Draw(ctx, cw, ch)
+	if s.Footer != nil {
+		_, mh := body.IntrinsicSize(ctx, 0, 0)
+		ctx.Translate(0, mh+DefaultBandPad)
+		if s.FooterDivider != nil {
+			w, _ := s.contentSize(ctx)
+			s.FooterDivider.Draw(ctx, w, s.FooterDivider.Thickness)
+			ctx.Translate(0, s.FooterDivider.Thickness+DefaultBandPad)
+		}
+		_, fh := s.Footer.IntrinsicSize(ctx, 0, 0)
+		s.Footer.Draw(ctx, cw, fh)
+	}
+	ctx.Pop()
+}
+
+// Layout defines how a pane arranges its objects into columns.
+type Layout int
+
+const (
+	LayoutAuto  Layout = iota // Search for the column count that gives the best canvas footprint
+	LayoutStack               // Stack all objects top to bottom in a single column
+	LayoutRow                 // Place all objects side by side in a single row, each column sized to its object
+)
+
+// OrderMode defines how the auto layout distributes objects across columns.
+type OrderMode int
+
+const (
+	OrderBalanced   OrderMode = iota // Push each object into the currently shortest column
+	OrderSequential                  // Fill the columns left to right with contiguous runs of objects, keeping column-major reading order
+)
+
 // Pane represents a container that holds multiple tileable objects (TextBlocks or ImageBlocks) and manages their layout.
 type Pane struct {
 	Objects      []Tileable // The objects within the pane, which can be TextBlocks or ImageBlocks
 	PlannedShape *Shape     // The planned shape of the pane after layout calculation
 	ColWidth     float64    // The fixed column width for tiling
-	ColPad       float64    // The padding between columns
-	RowPad       float64    // The padding between tiles in a column
+	ColPad       float64    // The padding between columns, raised to clear the Bleed of the objects
+	RowPad       float64    // The padding between tiles in a column, raised to clear the Bleed of the objects
+	Layout       Layout     // The layout mode used when calculating the shape
+	OrderMode    OrderMode  // How the auto layout distributes objects across columns
+	AutoColWidth bool       // Whether to shrink each column to its widest object instead of using the fixed ColWidth
+	// The number of rows for a fixed grid in reading order, with the column count derived from the object count.
+	// When set, it takes precedence over the layout search and objects are placed row by row instead of balancing column
+	// heights. The rows line up across the columns, each as tall as its tallest object.
+	GridRows int
+	// The column width as a fraction of the available canvas width, the max canvas width minus the outer padding.
+	// When positive, it overrides ColWidth for the panes of a scene, e.g. 0.5 for half-width columns.
+	ColWidthPct float64
+	// The number of characters per line used to derive the column width from the advance of the current font, e.g. 60
+	// for comfortably readable text columns. When positive, it overrides ColWidth. ColWidthPct takes precedence over it.
+	TargetCharsPerLine int
+	// The largest aspect ratio, max(w/h, h/w), allowed for the shape in auto layout. Zero means unlimited.
+	// If no column count fits within the ratio, the best shape overall is used.
+	MaxAspectRatio float64
+	// Whether to shrink the column width to the widest object before the layout search, capped at the configured width,
+	// so that small objects don't leave the columns mostly empty. Unlike AutoColWidth, the search itself uses the narrower width.
+	ShrinkColWidth bool
+	// The color filled behind the pane, e.g. for cards when nested in another pane. Semi-transparent colors blend with
+	// what is drawn beneath, so use color.NRGBA rather than the premultiplied color.RGBA for them. Nil draws no background.
+	Background color.Color
+	// The exact number of columns for the auto layout, skipping the search over column counts. The objects are still
+	// balanced across the columns following OrderMode. Zero searches for the best count, and counts above the number of objects are capped.
+	ColCount int
+	// Whether to move the objects of a LayoutRow pane down so that the first lines of text line up on a shared baseline,
+	// instead of their tops, e.g. for labels of different font sizes. Objects without a baseline, such as images, stay at the top.
+	AlignBaselines bool
+	// The space between the objects and the edges of the pane on every side, inside its background, e.g. for cards.
+	// The per-side paddings below take precedence over it where they are non-zero.
+	Padding   float64
+	PadTop    float64 // The space above the objects, overriding Padding when non-zero
+	PadRight  float64 // The space right of the objects, overriding Padding when non-zero
+	PadBottom float64 // The space below the objects, overriding Padding when non-zero
+	PadLeft   float64 // The space left of the objects, overriding Padding when non-zero
+
+	maxWidth     float64 // The width budget for the shape search, set by the engine from the max canvas width
+	widestObject float64 // The width of the widest object measured by Shape, used when ShrinkColWidth is set
 }
 
 func NewPane(objects []Tileable, colWidth float64, colPad float64, rowPad float64) *Pane {
@@ -197,6 +1223,13 @@ func NewPane(objects []Tileable, colWidth float64, colPad float64, rowPad float6
 	}
 }
 
+// NewPaneWithCols creates a pane that balances its objects into exactly cols columns instead of searching for the best count.
+func NewPaneWithCols(objects []Tileable, cols int, colWidth float64, colPad float64, rowPad float64) *Pane {
+	pane := NewPane(objects, colWidth, colPad, rowPad)
+	pane.ColCount = cols
+	return pane
+}
+
 func NewPaneWithShape(Shape *Shape, colWidth float64, colPad float64, rowPad float64) *Pane {
 	if colWidth == 0 {
 		colWidth = DefaultColWidth
@@ -218,16 +1251,47 @@ func NewPaneWithShape(Shape *Shape, colWidth float64, colPad float64, rowPad flo
 // Column represents a single column in the pane, containing multiple tileable objects. The column width will follow Pane.ColWidth when rendered.
 type Column struct {
 	Objects []Tileable
+	Width   float64 // The width of this column, overriding Pane.ColWidth when non-zero
+	// The gap between this column and the previous one, overriding Pane.ColPad when non-zero. It is ignored for the first column.
+	PadBefore float64
+	PadTop    float64 // The space above the first object of the column, e.g. to line up the baselines of a row
+}
+
+// EffectiveWidth returns the column's own width if set, otherwise the given pane column width.
+func (c Column) EffectiveWidth(colWidth float64) float64 {
+	if c.Width != 0 {
+		return c.Width
+	}
+	return colWidth
+}
+
+// EffectivePadBefore returns the column's own gap to the previous column if set, otherwise the given pane column padding.
+func (c Column) EffectivePadBefore(colPad float64) float64 {
+	if c.PadBefore != 0 {
+		return c.PadBefore
+	}
+	return colPad
+}
+
+// IntrinsicWidth returns the width of the widest object in the column when measured against the given column width.
+func (c Column) IntrinsicWidth(ctx *gg.Context, colWidth float64) float64 {
+	maxW := 0.0
+	for _, obj := range c.Objects {
+		w, _ := obj.IntrinsicSize(ctx, colWidth, 0)
+		maxW = math.Max(maxW, w)
+	}
+	return maxW
 }
 
 func (c Column) Height(ctx *gg.Context, colWidth float64, rowPad float64) float64 {
+	colWidth = c.EffectiveWidth(colWidth)
 	totalH := 0.0
 	for _, obj := range c.Objects {
 		_, h := obj.IntrinsicSize(ctx, colWidth, 0)
 		totalH += h
 	}
 	totalH += rowPad * float64(len(c.Objects)-1)
-	return totalH
+	return totalH + c.PadTop
 }
 
 // Shape stores the layout shape of the pane in terms of columns and rows. It's a temporary view of underlying objects calculated using the greedy algorithm to fit into the best canvas size.
@@ -266,6 +1330,23 @@ type Size struct {
 	Height float64
 }
 
+// colWidth returns the column width used for tiling, resolving ColWidthPct against the available width when set by the engine,
+// or TargetCharsPerLine against the font of the context. With ShrinkColWidth, it is capped at the widest object.
+func (p *Pane) colWidth(ctx *gg.Context) float64 {
+	colWidth := p.ColWidth
+	if p.ColWidthPct > 0 && p.maxWidth > 0 {
+		colWidth = p.ColWidthPct * p.maxWidth
+	} else if p.TargetCharsPerLine > 0 {
+		// the font is monospace, so the advance of any glyph is the average advance
+		advance, _ := ctx.MeasureString("0")
+		colWidth = float64(p.TargetCharsPerLine) * advance
+	}
+	if p.ShrinkColWidth && p.widestObject > 0 {
+		return math.Min(colWidth, p.widestObject)
+	}
+	return colWidth
+}
+
 // Calculate and return the shape of the column layout of the pane.
 // The algorithm finds the smallest footprint of canvas that can fit all objects in the pane.
 func (p *Pane) Shape(ctx *gg.Context) (Shape, Size) {
@@ -275,31 +1356,162 @@ func (p *Pane) Shape(ctx *gg.Context) (Shape, Size) {
 	areaDotAr := math.MaxFloat64
 	var bestShape *Shape
 	var bestSize Size
+	// the best shape regardless of the aspect ratio cap, used when no shape is within the cap
+	fallbackScore := math.MaxFloat64
+	var fallbackShape *Shape
+	var fallbackSize Size
 
 	// Create proxies
+	state := stateOf(ctx)
+	state.commit(func() { p.widestObject = 0 })
 	proxies := make([]Tileable, len(p.Objects))
+	widest := 0.0
 	for i, obj := range p.Objects {
-		w, h := obj.IntrinsicSize(ctx, p.ColWidth, 0)
+		// an abandoned render measures nothing more, and its shape is never used
+		if state.cancelled.Load() {
+			return Shape{}, Size{}
+		}
+		w, h := obj.IntrinsicSize(ctx, p.colWidth(ctx), 0)
 		proxies[i] = &TileProxy{Object: obj, Size: Size{Width: w, Height: h}}
+		widest = math.Max(widest, w)
+	}
+	if p.ShrinkColWidth {
+		state.commit(func() { p.widestObject = widest })
+	}
+	colPad, rowPad := p.pads(proxies)
+
+	if p.GridRows > 0 && len(proxies) > 0 {
+		s := NewShape((len(proxies) + p.GridRows - 1) / p.GridRows)
+		deriveGridShape(s, proxies)
+		alignGridRows(s)
+		p.fitColumns(ctx, s)
+		w, h := canvasSize(ctx, s, p.colWidth(ctx), colPad, rowPad)
+		return *s, Size{Width: w, Height: h}
 	}
 
-	for colCount := 1; colCount <= maxCol; colCount++ {
+	if p.Layout == LayoutStack {
+		s := NewShapeWithObjects([]Column{{Objects: proxies}})
+		p.fitColumns(ctx, s)
+		w, h := canvasSize(ctx, s, p.colWidth(ctx), colPad, rowPad)
+		return *s, Size{Width: w, Height: h}
+	}
+
+	// each object gets its own column, sized to its intrinsic width within ColWidth
+	if p.Layout == LayoutRow {
+		columns := make([]Column, len(proxies))
+		for i, proxy := range proxies {
+			w, _ := proxy.IntrinsicSize(ctx, p.colWidth(ctx), 0)
+			columns[i] = Column{Objects: []Tileable{proxy}, Width: w}
+		}
+		if p.AlignBaselines {
+			alignBaselines(ctx, columns, p.colWidth(ctx))
+		}
+		s := NewShapeWithObjects(columns)
+		w, h := canvasSize(ctx, s, p.colWidth(ctx), colPad, rowPad)
+		return *s, Size{Width: w, Height: h}
+	}
+
+	// an empty pane has no column counts to choose from
+	if len(proxies) == 0 {
+		return Shape{}, Size{}
+	}
+	minCol := 1
+	if p.ColCount > 0 {
+		minCol = min(p.ColCount, maxCol)
+		maxCol = minCol
+	}
+	for colCount := minCol; colCount <= maxCol; colCount++ {
+		if state.cancelled.Load() {
+			return Shape{}, Size{}
+		}
 		s := NewShape(colCount)
-		deriveShape(ctx, s, proxies, p.ColWidth, p.RowPad)
+		if p.OrderMode == OrderSequential {
+			deriveSequentialShape(ctx, s, proxies, p.colWidth(ctx), rowPad)
+		} else {
+			deriveShape(ctx, s, proxies, p.colWidth(ctx), rowPad)
+		}
+		p.fitColumns(ctx, s)
 
-		w, h := canvasSize(ctx, s, p.ColWidth, p.ColPad, p.RowPad)
+		w, h := canvasSize(ctx, s, p.colWidth(ctx), colPad, rowPad)
+		// skip shapes wider than the budget, but always keep the fewest columns as a fallback
+		if p.maxWidth > 0 && w > p.maxWidth && colCount > minCol {
+			continue
+		}
 		area := w * h
 		ar := math.Max(w/h, h/w)
-		if area*ar < areaDotAr {
+		// column counts are tried in increasing order, so keeping the incumbent on ties makes fewer columns win
+		if betterScore(area*ar, fallbackScore) {
+			fallbackShape = s
+			fallbackSize = Size{Width: w, Height: h}
+			fallbackScore = area * ar
+		}
+		if p.MaxAspectRatio > 0 && ar > p.MaxAspectRatio {
+			continue
+		}
+		if betterScore(area*ar, areaDotAr) {
 			bestShape = s
 			bestSize = Size{Width: w, Height: h}
 			areaDotAr = area * ar
 		}
 	}
 
+	if bestShape == nil {
+		return *fallbackShape, fallbackSize
+	}
 	return *bestShape, bestSize
 }
 
+// pads returns the column and row padding between the objects, raised to twice the largest Bleed of the objects, so that
+// the decorations spilling out of adjacent objects, such as text shadows and outlines, never overlap. The gaps set on
+// individual columns are not raised.
+func (p *Pane) pads(objects []Tileable) (float64, float64) {
+	floor := 0.0
+	for _, obj := range objects {
+		if b, ok := unwrapProxy(obj).(interface{ Bleed() float64 }); ok {
+			floor = math.Max(floor, 2*b.Bleed())
+		}
+	}
+	return math.Max(p.ColPad, floor), math.Max(p.RowPad, floor)
+}
+
+// objects returns the objects of all columns of the shape.
+func (s *Shape) objects() []Tileable {
+	var objects []Tileable
+	for _, column := range s.Columns {
+		objects = append(objects, column.Objects...)
+	}
+	return objects
+}
+
+// The relative tolerance under which two shape scores are considered equal.
+const scoreEpsilon = 1e-9
+
+// betterScore reports whether score is lower than best by more than the relative tolerance, so that
+// layouts whose scores only differ by float rounding are treated as ties.
+func betterScore(score float64, best float64) bool {
+	return score < best-math.Abs(best)*scoreEpsilon
+}
+
+// Shrink the columns without an explicit width to their widest object, when AutoColWidth is enabled.
+func (p *Pane) fitColumns(ctx *gg.Context, s *Shape) {
+	if !p.AutoColWidth {
+		return
+	}
+	for i := range s.Columns {
+		if s.Columns[i].Width == 0 {
+			s.Columns[i].Width = s.Columns[i].IntrinsicWidth(ctx, p.colWidth(ctx))
+		}
+	}
+}
+
+// plannedShape returns a copy of PlannedShape with its columns fitted like fitColumns, leaving the caller's shape as set.
+func (p *Pane) plannedShape(ctx *gg.Context) Shape {
+	shape := *p.PlannedShape
+	shape.Columns = slices.Clone(shape.Columns)
+	p.fitColumns(ctx, &shape)
+	return shape
+}
+
 // Greedy algorithm to push tiles into the shape's columns based on the given column width
 func deriveShape(ctx *gg.Context, s *Shape, t []Tileable, colWidth float64, rowPad float64) {
 	colCount := len(s.Columns)
@@ -308,6 +1520,7 @@ func deriveShape(ctx *gg.Context, s *Shape, t []Tileable, colWidth float64, rowP
 		minHeight := math.MaxFloat64
 		for colIndex := range colCount {
 			h := s.Columns[colIndex].Height(ctx, colWidth, rowPad)
+			// strict comparison keeps the leftmost column on ties so the placement is deterministic
 			if h < minHeight {
 				minHeight = h
 				minHeightCol = colIndex
@@ -317,10 +1530,69 @@ func deriveShape(ctx *gg.Context, s *Shape, t []Tileable, colWidth float64, rowP
 	}
 }
 
+// Split tiles into contiguous runs of roughly equal height, one run per column, preserving their order.
+func deriveSequentialShape(ctx *gg.Context, s *Shape, t []Tileable, colWidth float64, rowPad float64) {
+	colCount := len(s.Columns)
+	heights := make([]float64, len(t))
+	total := 0.0
+	for i, tile := range t {
+		_, heights[i] = tile.IntrinsicSize(ctx, colWidth, 0)
+		total += heights[i] + rowPad
+	}
+	target := total / float64(colCount)
+	offset := 0.0
+	col := 0
+	for i, tile := range t {
+		// a tile goes to the column its vertical midpoint falls in along the total height
+		mid := offset + heights[i]/2
+		for col < colCount-1 && mid > target*float64(col+1) {
+			col++
+		}
+		s.Columns[col].Objects = append(s.Columns[col].Objects, tile)
+		offset += heights[i] + rowPad
+	}
+}
+
+// Push tiles into the shape's columns in row-major order, so that each row holds the next len(s.Columns) tiles.
+func deriveGridShape(s *Shape, t []Tileable) {
+	for i, tile := range t {
+		col := i % len(s.Columns)
+		s.Columns[col].Objects = append(s.Columns[col].Objects, tile)
+	}
+}
+
+// alignGridRows gives the proxies of each row of a grid the height of the tallest one, so that the rows line up across
+// the columns and every object is drawn in the full height of its row, e.g. for vertically aligned text.
+func alignGridRows(s *Shape) {
+	for row := 0; ; row++ {
+		var proxies []*TileProxy
+		tallest := 0.0
+		for _, column := range s.Columns {
+			if row < len(column.Objects) {
+				proxy := column.Objects[row].(*TileProxy)
+				proxies = append(proxies, proxy)
+				tallest = math.Max(tallest, proxy.Size.Height)
+			}
+		}
+		if len(proxies) == 0 {
+			return
+		}
+		for _, proxy := range proxies {
+			proxy.Size.Height = tallest
+		}
+	}
+}
+
 // Calculate the canvas size based on the layout of given shape.
 func canvasSize(ctx *gg.Context, shape *Shape, colWidth float64, colPad float64, rowPad float64) (float64, float64) {
 	colCount := len(shape.Columns)
-	totalW := float64(colCount)*colWidth + float64(colCount-1)*colPad
+	totalW := 0.0
+	for colIndex, column := range shape.Columns {
+		if colIndex > 0 {
+			totalW += column.EffectivePadBefore(colPad)
+		}
+		totalW += column.EffectiveWidth(colWidth)
+	}
 	maxH := 0.0
 	for colIndex := range colCount {
 		h := shape.Columns[colIndex].Height(ctx, colWidth, rowPad)
@@ -333,85 +1605,283 @@ func canvasSize(ctx *gg.Context, shape *Shape, colWidth float64, colPad float64,
 
 // Draw the pane onto the given context based on the provided shape.
 func (p *Pane) DrawShape(ctx *gg.Context, shape Shape) {
-	rPad := DefaultMinPad
-	for colCount, column := range shape.Columns {
+	colPad, rPad := p.pads(shape.objects())
+	total := 0
+	for _, column := range shape.Columns {
+		total += len(column.Objects)
+	}
+	state := stateOf(ctx)
+	// only the drawing of the main pane is recorded and reported
+	main := state.mainPane == p
+	if main && state.layout != nil {
+		state.layout.Shape = unwrapShape(shape)
+		state.layout.Bounds = make([][]image.Rectangle, len(shape.Columns))
+	}
+	// the objects of a row layout are drawn in the full height of the row, e.g. for vertically aligned text
+	rowHeight := 0.0
+	if p.Layout == LayoutRow && p.GridRows == 0 {
+		_, rowHeight = canvasSize(ctx, &shape, p.colWidth(ctx), colPad, rPad)
+	}
+	done := 0
+	translateX := 0.0
+	for colIndex, column := range shape.Columns {
+		if state.cancelled.Load() {
+			break
+		}
+		colWidth := column.EffectiveWidth(p.colWidth(ctx))
+		if colIndex > 0 {
+			translateX += column.EffectivePadBefore(colPad)
+		}
 		ctx.Push()
-		translateX := p.ColWidth*float64(colCount) + p.ColPad*float64(colCount)
 		ctx.Translate(translateX, 0)
+		if stateOf(ctx).debugGrid {
+			strokeDebugBox(ctx, colWidth, column.Height(ctx, colWidth, rPad))
+		}
+		ctx.Translate(0, column.PadTop)
 		for _, obj := range column.Objects {
-			w, h := obj.IntrinsicSize(ctx, p.ColWidth, 0)
-			obj.Draw(ctx, w, h)
+			if state.cancelled.Load() {
+				break
+			}
+			w, h := obj.IntrinsicSize(ctx, colWidth, 0)
+			if len(column.Objects) == 1 {
+				h = math.Max(h, rowHeight-column.PadTop)
+			}
+			// objects aligning themselves within the column get the full column width to draw in
+			if f, ok := unwrapProxy(obj).(interface{ fillsColumn() bool }); ok && f.fillsColumn() {
+				w = math.Max(w, colWidth)
+			}
+			drawObject(ctx, obj, w, h)
+			if stateOf(ctx).debugGrid {
+				strokeDebugBox(ctx, w, h)
+			}
+			recordRegion(ctx, unwrapProxy(obj), w, h)
+			if main && state.layout != nil {
+				state.layout.Bounds[colIndex] = append(state.layout.Bounds[colIndex], deviceRect(ctx, w, h))
+			}
 			ctx.Translate(0, h+rPad)
+			done++
+			if main && state.onProgress != nil {
+				state.commit(func() { state.onProgress(done, total) })
+			}
 		}
 		ctx.Pop()
+		translateX += colWidth
 	}
 }
 
-func (p *Pane) Draw(ctx *gg.Context, cw float64, ch float64) {
-	if p.PlannedShape != nil {
-		p.DrawShape(ctx, *p.PlannedShape)
-	} else {
-		shape, _ := p.Shape(ctx)
-		p.PlannedShape = &shape
-		p.DrawShape(ctx, shape)
+// alignBaselines pads the tops of the columns so that the baselines of their first objects line up with the lowest one.
+func alignBaselines(ctx *gg.Context, columns []Column, colWidth float64) {
+	baselines := make([]float64, len(columns))
+	lowest := 0.0
+	for i, column := range columns {
+		if len(column.Objects) == 0 {
+			continue
+		}
+		if b, ok := baselineOf(ctx, column.Objects[0], column.EffectiveWidth(colWidth)); ok {
+			baselines[i] = b
+			lowest = math.Max(lowest, b)
+		} else {
+			baselines[i] = math.NaN()
+		}
+	}
+	for i, b := range baselines {
+		if !math.IsNaN(b) {
+			columns[i].PadTop = lowest - b
+		}
 	}
 }
 
-func (p *Pane) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
-	if p.PlannedShape != nil {
-		return canvasSize(ctx, p.PlannedShape, p.ColWidth, p.ColPad, p.RowPad)
-	} else {
-		shape, size := p.Shape(ctx)
-		p.PlannedShape = &shape
-		return size.Width, size.Height
+// baselineOf returns the distance from the top of an object to the baseline of its first line of text, for objects
+// with a Baseline method such as text blocks and badges, looking through layout proxies and margins. Rotated text
+// blocks have no horizontal baseline and stay at the top like objects without one.
+func baselineOf(ctx *gg.Context, obj Tileable, width float64) (float64, bool) {
+	obj = unwrapProxy(obj)
+	if m, ok := obj.(*Margined); ok {
+		w, _ := m.inner(width, 0)
+		b, ok := baselineOf(ctx, m.Object, w)
+		return b + m.Margin.Top, ok
+	}
+	if t, ok := obj.(*TextBlock); ok && t.rotation() != 0 {
+		return 0, false
 	}
+	if b, ok := obj.(interface {
+		Baseline(ctx *gg.Context, expectedWidth float64) float64
+	}); ok {
+		return b.Baseline(ctx, width), true
+	}
+	return 0, false
 }
 
-type TextBlockOpts struct {
-	TextWrap bool // Whether to wrap text if it exceeds the pane width
+// strokeDebugBox outlines the box of the given size at the origin of the current transform with a 1 pixel line inside the box.
+func strokeDebugBox(ctx *gg.Context, w float64, h float64) {
+	ctx.Push()
+	ctx.SetColor(DebugGridColor)
+	ctx.SetLineWidth(1)
+	x0, y0 := snapStroke(ctx, 0.5, 0.5, 1)
+	x1, y1 := snapStroke(ctx, math.Max(w-0.5, 0.5), math.Max(h-0.5, 0.5), 1)
+	ctx.DrawRectangle(x0, y0, x1-x0, y1-y0)
+	ctx.Stroke()
+	ctx.Pop()
 }
 
-type TextBlock struct {
-	// Representation of a plain-text.
-	Text string
-	Opts TextBlockOpts
+// drawObject draws a pane object, unless a layer of another object is being rendered.
+func drawObject(ctx *gg.Context, obj Tileable, w float64, h float64) {
+	state := stateOf(ctx)
+	if state.layer == nil || state.inLayer {
+		obj.Draw(ctx, w, h)
+		return
+	}
+	if unwrapProxy(obj) == state.layer {
+		state.inLayer = true
+		obj.Draw(ctx, w, h)
+		state.inLayer = false
+	}
 }
 
-func NewTextBlock(text string, opts TextBlockOpts) *TextBlock {
-	return &TextBlock{Text: text, Opts: opts}
+// unwrapShape returns a copy of the shape with the layout proxies replaced by the objects they stand for.
+func unwrapShape(shape Shape) Shape {
+	columns := make([]Column, len(shape.Columns))
+	for i, column := range shape.Columns {
+		columns[i] = Column{Objects: make([]Tileable, len(column.Objects)), Width: column.Width, PadBefore: column.PadBefore, PadTop: column.PadTop}
+		for j, obj := range column.Objects {
+			columns[i].Objects[j] = unwrapProxy(obj)
+		}
+	}
+	return Shape{Columns: columns}
 }
 
-func (t *TextBlock) Draw(ctx *gg.Context, cw float64, ch float64) {
-	if t.Opts.TextWrap == false {
-		ctx.DrawStringAnchored(t.Text, 0, 0, 0, 1)
-	} else {
-		maxWidth := float64(cw)
-		ctx.DrawStringWrapped(t.Text, 0, 0, 0, 0, maxWidth, DefaultLineSpacing, gg.AlignLeft)
+// countObjects returns the number of objects in the pane, including the objects of nested panes.
+func (p *Pane) countObjects() int {
+	objects := p.Objects
+	if p.PlannedShape != nil {
+		objects = nil
+		for _, column := range p.PlannedShape.Columns {
+			objects = append(objects, column.Objects...)
+		}
+	}
+	count := len(objects)
+	for _, obj := range objects {
+		count += countNested(obj)
+	}
+	return count
+}
+
+// countNested returns the number of objects nested in obj, unwrapping proxies and margins however deeply they wrap a
+// pane or a row of panes.
+func countNested(obj Tileable) int {
+	for {
+		switch o := obj.(type) {
+		case *TileProxy:
+			obj = o.Object
+		case *Margined:
+			obj = o.Object
+		case *Pane:
+			return o.countObjects()
+		case *paneRow:
+			count := 0
+			for _, pane := range o.panes {
+				if pane != nil {
+					count += pane.countObjects()
+				}
+			}
+			return count
+		default:
+			return 0
+		}
 	}
 }
 
-func (t *TextBlock) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+// unwrapProxy returns the object a layout proxy stands for, or the object itself if it isn't a proxy.
+func unwrapProxy(obj Tileable) Tileable {
+	if proxy, ok := obj.(*TileProxy); ok {
+		return proxy.Object
+	}
+	return obj
+}
 
-	if expectedWidth == 0 {
-		return ctx.MeasureMultilineString(t.Text, DefaultLineSpacing)
+func (p *Pane) Draw(ctx *gg.Context, cw float64, ch float64) {
+	if p.Background != nil {
+		w, h := p.IntrinsicSize(ctx, cw, ch)
+		ctx.Push()
+		ctx.SetColor(p.Background)
+		fillRect(ctx, 0, 0, w, h)
+		ctx.Pop()
+	}
+	if pad := p.padding(); pad.Left != 0 || pad.Top != 0 {
+		ctx.Push()
+		defer ctx.Pop()
+		ctx.Translate(pad.Left, pad.Top)
+	}
+	if p.PlannedShape != nil {
+		p.DrawShape(ctx, p.plannedShape(ctx))
 	} else {
-		lines := ctx.WordWrap(t.Text, expectedWidth)
-		maxWidth := 0.0
-		for _, line := range lines {
-			w, _ := ctx.MeasureString(line)
-			if w > maxWidth {
-				maxWidth = w
-			}
+		shape, _ := p.Shape(ctx)
+		stateOf(ctx).commit(func() { p.PlannedShape = &shape })
+		p.DrawShape(ctx, shape)
+	}
+}
+
+func (p *Pane) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	var w, h float64
+	if p.PlannedShape != nil {
+		shape := p.plannedShape(ctx)
+		colPad, rowPad := p.pads(shape.objects())
+		w, h = canvasSize(ctx, &shape, p.colWidth(ctx), colPad, rowPad)
+	} else {
+		shape, size := p.Shape(ctx)
+		stateOf(ctx).commit(func() { p.PlannedShape = &shape })
+		w, h = size.Width, size.Height
+	}
+	pad := p.padding()
+	return w + pad.Left + pad.Right, h + pad.Top + pad.Bottom
+}
+
+// padding returns the space around the objects on each side, the per-side padding where set and Padding elsewhere.
+func (p *Pane) padding() Margin {
+	side := func(v float64) float64 {
+		if v != 0 {
+			return v
 		}
-		totalHeight := float64(len(lines)) * ctx.FontHeight() * DefaultLineSpacing
-		return maxWidth, totalHeight
+		return p.Padding
 	}
+	return Margin{Top: side(p.PadTop), Right: side(p.PadRight), Bottom: side(p.PadBottom), Left: side(p.PadLeft)}
 }
 
+type ImageBlockOpts struct {
+	Resampling Resampling // The kernel used to resize the image to its drawn size
+	HAlign     HAlign     // The horizontal alignment of the image and its label within a wider column. HAlignStart follows the direction of the label.
+	LabelPad   float64    // The gap between the image and its label. Zero means DefaultLabelPad.
+	MaxHeight  float64    // The maximum height of the drawn image, excluding the label. Taller images are scaled down to fit. Zero means unlimited.
+	// The maximum width of the drawn image, regardless of the column width, so that a huge image doesn't dominate a wide
+	// column. Wider images are scaled down to fit. Zero means unlimited.
+	MaxDisplayWidth float64
+	// Whether to draw the label over the bottom of the image on a dark gradient scrim instead of below it, so that the
+	// block is only as tall as the image. The label is inset by LabelPad and drawn in white unless it has a color of its own.
+	LabelOverlay bool
+	// The title drawn above the image, in addition to the label below it, e.g. for before/after comparisons. It is
+	// styled like the label and separated from the image by LabelPad. Empty draws no title.
+	TopLabel string
+	// The aspect ratio, width over height, the image is center-cropped to before layout, e.g. 1 for a uniform grid of
+	// squares instead of letterboxed images. The block is measured and drawn from the cropped image. Zero keeps the full image.
+	CropAspect float64
+}
+
+// The color the scrim behind an overlaid image label fades into at the bottom of the image.
+var overlayScrimColor = color.NRGBA{A: 0xb4}
+
 type ImageBlock struct {
 	// Representation of an image, with a custom label for identification.
 	Image image.Image
 	Label *TextBlock
+	Opts  ImageBlockOpts
+
+	cacheMu       sync.Mutex  // Guards the caches below, as engines may draw scenes sharing the block concurrently
+	resized       image.Image // The image pre-resized for the last drawn size, when a resampling kernel is set
+	cropped       image.Image // The image cropped to croppedAspect, when CropAspect is set
+	croppedAspect float64     // The aspect ratio the cropped image was cropped to
+	tinted        image.Image // The image tintedFrom tinted by tintedBy, when the render has an ImageTint
+	tintedFrom    image.Image // The image the tinted image was made from, the source or the resized image
+	tintedBy      ImageTint   // The tint the tinted image was made with
 }
 
 func NewImageBlock(file io.Reader, label string) (*ImageBlock, error) {
@@ -420,58 +1890,308 @@ func NewImageBlock(file io.Reader, label string) (*ImageBlock, error) {
 		fmt.Println("NewImageBlock: failed to load image from bytes:", err)
 		return nil, err
 	}
-	textblock := NewTextBlock(label, TextBlockOpts{TextWrap: true})
-	return &ImageBlock{Image: img, Label: textblock}, nil
+	return NewImageBlockFromImage(img, label), nil
+}
+
+// NewImageBlockFromBytes creates an image block like NewImageBlock from encoded image data already in memory,
+// e.g. read from a database or an upload.
+func NewImageBlockFromBytes(data []byte, label string) (*ImageBlock, error) {
+	return NewImageBlock(bytes.NewReader(data), label)
+}
+
+// NewImageBlockWithLabelOpts creates an image block like NewImageBlock, with the label styled by the given options
+// instead of the default TextBlockOpts{TextWrap: true, HAlign: HAlignStart}, e.g. to center, color or size captions independently.
+func NewImageBlockWithLabelOpts(file io.Reader, label string, opts TextBlockOpts) (*ImageBlock, error) {
+	block, err := NewImageBlock(file, label)
+	if err != nil {
+		return nil, err
+	}
+	block.Label.Opts = opts
+	return block, nil
+}
+
+// NewImageBlockOrPlaceholder creates an image block like NewImageBlock, but substitutes a gray placeholder image of the
+// default placeholder size when the image fails to decode, so that one bad image doesn't break the whole scene.
+func NewImageBlockOrPlaceholder(file io.Reader, label string) *ImageBlock {
+	block, err := NewImageBlock(file, label)
+	if err != nil {
+		return NewImageBlockFromImage(placeholderImage(DefaultPlaceholderWidth, DefaultPlaceholderHeight), label)
+	}
+	return block
+}
+
+// placeholderImage returns a solid image of the placeholder color.
+func placeholderImage(width int, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(PlaceholderColor), image.Point{}, draw.Src)
+	return img
+}
+
+// NewImageBlockFromImage creates an image block from an already decoded image, e.g. one generated in memory,
+// with a wrapped text label. The label is aligned to the start of its text direction, so that right-to-left captions
+// sit under the right edge of the image.
+func NewImageBlockFromImage(img image.Image, label string) *ImageBlock {
+	textblock := NewTextBlock(label, TextBlockOpts{TextWrap: true, HAlign: HAlignStart})
+	return &ImageBlock{Image: img, Label: textblock}
 }
 
 func (i *ImageBlock) Draw(ctx *gg.Context, cw float64, ch float64) {
 	// scale down image if necessary
 	ctx.Push()
+	width, _ := i.imageSize(cw, 0)
+	if offset := cw - width; offset > 0 && i.hAlign() != HAlignLeft {
+		if i.hAlign() == HAlignCenter {
+			offset /= 2
+		}
+		ctx.Translate(offset, 0)
+	}
+	if topHeight := i.topLabelHeight(ctx, width); topHeight > 0 {
+		i.drawTopLabel(ctx, width)
+		ctx.Translate(0, topHeight)
+		ch -= topHeight
+	}
+	img := i.source()
 	ctx.Push()
 	scale := 1.0
-	if float64(i.Image.Bounds().Dx()) > cw {
-		scale = cw / float64(i.Image.Bounds().Dx())
+	if float64(img.Bounds().Dx()) > width {
+		scale = width / float64(img.Bounds().Dx())
 		ctx.Scale(scale, scale)
 	}
-	ctx.DrawImageAnchored(i.Image, 0, 0, 0, 0)
+	if interpolator := i.Opts.Resampling.interpolator(); interpolator != nil {
+		i.drawResized(ctx, interpolator)
+	} else {
+		drawImage(ctx, i.tint(ctx, img))
+	}
+	ctx.Pop()
+	imageWidth := float64(img.Bounds().Dx()) * scale
+	imageHeight := float64(img.Bounds().Dy()) * scale
+	if i.Opts.LabelOverlay {
+		i.drawOverlayLabel(ctx, imageWidth, imageHeight)
+		ctx.Pop()
+		return
+	}
+	ctx.Translate(0, imageHeight+i.labelPad())
+	label := i.Label.withColor(stateOf(ctx).captionColor(i.Label.Opts.Color))
+	// wrap the label to the scaled image width, matching the width used in IntrinsicSize
+	i.withCaptionFace(ctx, func() {
+		label.Draw(ctx, imageWidth, ch-imageHeight-i.labelPad())
+	})
+	ctx.Pop()
+}
+
+// topLabel returns the title drawn above the image, styled like the label.
+func (i *ImageBlock) topLabel() *TextBlock {
+	return &TextBlock{Text: i.Opts.TopLabel, Opts: i.Label.Opts}
+}
+
+// topLabelHeight returns the height reserved above an image of the given width for the top label and its gap to the
+// image, or zero without a top label.
+func (i *ImageBlock) topLabelHeight(ctx *gg.Context, width float64) float64 {
+	if i.Opts.TopLabel == "" {
+		return 0
+	}
+	var textHeight float64
+	i.withCaptionFace(ctx, func() {
+		_, textHeight = i.topLabel().IntrinsicSize(ctx, width, 0)
+	})
+	return textHeight + i.labelPad()
+}
+
+// drawTopLabel draws the top label wrapped to the image width at the origin.
+func (i *ImageBlock) drawTopLabel(ctx *gg.Context, width float64) {
+	label := i.topLabel().withColor(stateOf(ctx).captionColor(i.Label.Opts.Color))
+	i.withCaptionFace(ctx, func() {
+		label.Draw(ctx, width, 0)
+	})
+}
+
+// drawOverlayLabel draws the label over the bottom of an image of the given size, on a scrim fading in from above the text.
+func (i *ImageBlock) drawOverlayLabel(ctx *gg.Context, imageWidth float64, imageHeight float64) {
+	if i.Label.Text == "" {
+		return
+	}
+	pad := i.labelPad()
+	textWidth := math.Max(imageWidth-2*pad, 1)
+	var textHeight float64
+	i.withCaptionFace(ctx, func() {
+		_, textHeight = i.Label.IntrinsicSize(ctx, textWidth, 0)
+	})
+	// the scrim starts fading in above the text, so that the band has no hard top edge
+	top := math.Max(imageHeight-2*(textHeight+2*pad), 0)
+	ctx.Push()
+	scrim := gg.NewLinearGradient(0, top, 0, imageHeight)
+	scrim.AddColorStop(0, color.Transparent)
+	scrim.AddColorStop(1, overlayScrimColor)
+	ctx.SetFillStyle(scrim)
+	ctx.DrawRectangle(0, top, imageWidth, imageHeight-top)
+	ctx.Fill()
+	ctx.Translate(pad, math.Max(imageHeight-pad-textHeight, 0))
+	label := i.Label.withColor(color.White)
+	i.withCaptionFace(ctx, func() {
+		label.Draw(ctx, textWidth, textHeight)
+	})
 	ctx.Pop()
-	imageHeight := float64(i.Image.Bounds().Dy()) * scale
-	ctx.Translate(0, imageHeight+DefaultLabelPad)
-	i.Label.Draw(ctx, cw, ch-imageHeight-DefaultLabelPad)
+}
+
+// drawResized resizes the image to its size in device pixels with the interpolator and draws it unscaled,
+// so that the kernel rather than the draw-time sampling determines the quality.
+func (i *ImageBlock) drawResized(ctx *gg.Context, interpolator draw.Interpolator) {
+	img := i.source()
+	// round the corners rather than the outward deviceRect, so that the image spans the pixels the scaled draw would cover
+	x0, y0 := ctx.TransformPoint(0, 0)
+	x1, y1 := ctx.TransformPoint(float64(img.Bounds().Dx()), float64(img.Bounds().Dy()))
+	bounds := image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1))).Canon()
+	if bounds.Empty() {
+		return
+	}
+	i.cacheMu.Lock()
+	if i.resized == nil || i.resized.Bounds().Size() != bounds.Size() {
+		i.resized = resizeImage(img, bounds.Size(), interpolator)
+	}
+	resized := i.resized
+	i.cacheMu.Unlock()
+	ctx.Push()
+	ctx.Identity()
+	ctx.Translate(float64(bounds.Min.X), float64(bounds.Min.Y))
+	drawImage(ctx, i.tint(ctx, resized))
 	ctx.Pop()
 }
 
+// tint returns the image tinted by the ImageTint of the render. The tinted image is cached like the resized one, so
+// that drawing the canvas in strips doesn't tint the full image again for every strip.
+func (i *ImageBlock) tint(ctx *gg.Context, img image.Image) image.Image {
+	tint := stateOf(ctx).imageTint
+	if tint.Color == nil || tint.Strength <= 0 {
+		return img
+	}
+	i.cacheMu.Lock()
+	defer i.cacheMu.Unlock()
+	if i.tinted == nil || i.tintedFrom != img || i.tintedBy != tint {
+		i.tinted, i.tintedFrom, i.tintedBy = tint.apply(img), img, tint
+	}
+	return i.tinted
+}
+
+// fillsColumn reports whether the block is drawn across the full column width, so that it can align itself within it.
+func (i *ImageBlock) fillsColumn() bool {
+	return i.hAlign() != HAlignLeft
+}
+
+// hAlign returns the alignment of the block within a wider column, with HAlignStart resolved to the direction of the label.
+func (i *ImageBlock) hAlign() HAlign {
+	return i.Opts.HAlign.resolve(i.Label.Text)
+}
+
 func (i *ImageBlock) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
-	w := float64(i.Image.Bounds().Dx())
-	h := float64(i.Image.Bounds().Dy())
-	scale := 1.0
+	width, imageHeight := i.imageSize(expectedWidth, expectedHeight)
+	topHeight := i.topLabelHeight(ctx, width)
+	if i.Opts.LabelOverlay {
+		return width, topHeight + imageHeight
+	}
+	var textHeight float64
+	i.withCaptionFace(ctx, func() {
+		_, textHeight = i.Label.IntrinsicSize(ctx, width, 0)
+	})
+	return width, topHeight + imageHeight + textHeight + i.labelPad()
+}
+
+// Bleed returns how far the shadow and outline of the label may spill out of the block, or zero for overlaid labels,
+// which are drawn within the image, and for blocks without a label.
+func (i *ImageBlock) Bleed() float64 {
+	if i.Opts.LabelOverlay || i.Label == nil {
+		return 0
+	}
+	return i.Label.Bleed()
+}
+
+// withCaptionFace runs fn with the caption font size of the render set on the context, unless the label has a size of its own.
+func (i *ImageBlock) withCaptionFace(ctx *gg.Context, fn func()) {
+	size := stateOf(ctx).captionFontSize
+	if size == 0 || i.Label.Opts.FontSize != 0 || i.Label.Opts.AutoFit {
+		fn()
+		return
+	}
+	i.Label.withFontSize(ctx, size, fn)
+}
+
+// labelPad returns the gap between the image and its label, falling back to DefaultLabelPad.
+func (i *ImageBlock) labelPad() float64 {
+	if i.Opts.LabelPad > 0 {
+		return i.Opts.LabelPad
+	}
+	return DefaultLabelPad
+}
+
+// source returns the image the block is drawn from, center-cropped to CropAspect when set.
+func (i *ImageBlock) source() image.Image {
+	if i.Opts.CropAspect <= 0 {
+		return i.Image
+	}
+	i.cacheMu.Lock()
+	defer i.cacheMu.Unlock()
+	if i.cropped == nil || i.croppedAspect != i.Opts.CropAspect {
+		i.cropped, i.croppedAspect = cropToAspect(i.Image, i.Opts.CropAspect), i.Opts.CropAspect
+		i.resized = nil
+	}
+	return i.cropped
+}
+
+// cropToAspect returns the largest centered part of the image with the given aspect ratio, width over height,
+// or the image itself if it already has the ratio.
+func cropToAspect(img image.Image, aspect float64) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	cropW, cropH := w, h
+	if float64(w)/float64(h) > aspect {
+		cropW = max(1, int(math.Round(float64(h)*aspect)))
+	} else {
+		cropH = max(1, int(math.Round(float64(w)/aspect)))
+	}
+	if cropW == w && cropH == h {
+		return img
+	}
+	// copied rather than sub-imaged, since images are drawn from their bounds origin
+	dst := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+	offset := bounds.Min.Add(image.Pt((w-cropW)/2, (h-cropH)/2))
+	draw.Draw(dst, dst.Bounds(), img, offset, draw.Src)
+	return dst
+}
+
+// imageSize returns the size the image is drawn at within the expected size, without the label.
+func (i *ImageBlock) imageSize(expectedWidth float64, expectedHeight float64) (float64, float64) {
+	w := float64(i.source().Bounds().Dx())
+	h := float64(i.source().Bounds().Dy())
+	var width, height float64
 	if expectedWidth == 0 && expectedHeight == 0 {
 		expectedWidth = w
 	}
 	if expectedWidth != 0 && expectedHeight == 0 {
 		// only scale down image but not scale up
+		scale := 1.0
 		if expectedWidth > w {
 			expectedWidth = w
-			scale = 1.0
 		} else {
 			scale = expectedWidth / w
 		}
-		_, textHeight := i.Label.IntrinsicSize(ctx, expectedWidth, 0)
-		return expectedWidth, h*scale + textHeight + DefaultLabelPad
+		width, height = expectedWidth, h*scale
 	} else if expectedWidth == 0 && expectedHeight != 0 {
 		scale := expectedHeight / h
-		newWidth := w * scale
-		_, textHeight := i.Label.IntrinsicSize(ctx, newWidth, 0)
-		return newWidth, expectedHeight + textHeight + DefaultLabelPad
+		width, height = w*scale, expectedHeight
 	} else {
 		// both width and height are defined, we scale based on the smaller scale factor to fit within the box
 		scaleW := expectedWidth / w
 		scaleH := expectedHeight / h
 		scale := math.Min(scaleW, scaleH)
-		newWidth := w * scale
-		_, textHeight := i.Label.IntrinsicSize(ctx, newWidth, 0)
-		return newWidth, h*scale + textHeight + DefaultLabelPad
+		width, height = w*scale, h*scale
+	}
+	// scale down further to cap the width and height, drawing happens at the capped width
+	if i.Opts.MaxDisplayWidth > 0 && width > i.Opts.MaxDisplayWidth {
+		width, height = i.Opts.MaxDisplayWidth, height*i.Opts.MaxDisplayWidth/width
+	}
+	if i.Opts.MaxHeight > 0 && height > i.Opts.MaxHeight {
+		width, height = width*i.Opts.MaxHeight/height, i.Opts.MaxHeight
 	}
+	return width, height
 }
 
 func NewScene(main *Pane) *Scene {
@@ -1,13 +1,29 @@
 package imacon
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/fogleman/gg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 func Test_IntrinsicSize(t *testing.T) {
@@ -261,3 +277,1715 @@ func Test_Render(t *testing.T) {
 		})
 	}
 }
+
+func loadImageBlock(t testing.TB, path string, label string) *ImageBlock {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	img, err := NewImageBlock(f, label)
+	require.NoError(t, err)
+	return img
+}
+
+func Test_PaneLayout(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+
+	objects := make([]Tileable, 6)
+	for i := range objects {
+		objects[i] = loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1))
+	}
+
+	t.Run("Stack layout preserves order", func(t *testing.T) {
+		pane := NewPane(objects, 0, 0, 0)
+		pane.Layout = LayoutStack
+		shape, size := pane.Shape(ctx)
+		require.Len(t, shape.Columns, 1, "Stack layout should produce a single column")
+		require.Len(t, shape.Columns[0].Objects, len(objects))
+		for i, obj := range shape.Columns[0].Objects {
+			assert.Same(t, objects[i], obj.(*TileProxy).Object, "Objects should be stacked in their original order")
+		}
+		assert.Equal(t, DefaultColWidth, size.Width, "Width should match a single column")
+	})
+
+	t.Run("Row layout places objects side by side", func(t *testing.T) {
+		row := []Tileable{
+			loadImageBlock(t, "assets/samples/glasses.png", "Glasses"),
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+			loadImageBlock(t, "assets/samples/sample_3.jpg", "Necklace"),
+		}
+		pane := NewPane(row, 0, 0, 0)
+		pane.Layout = LayoutRow
+		shape, size := pane.Shape(ctx)
+		require.Len(t, shape.Columns, len(row), "Row layout should put each object in its own column")
+
+		expectedW := DefaultColPad * float64(len(row)-1)
+		expectedH := 0.0
+		for i, column := range shape.Columns {
+			w, h := row[i].IntrinsicSize(ctx, DefaultColWidth, 0)
+			assert.Same(t, row[i], column.Objects[0].(*TileProxy).Object, "Objects should be placed left to right in order")
+			assert.Equal(t, w, column.Width, "Column width should match the object's intrinsic width")
+			expectedW += w
+			expectedH = math.Max(expectedH, h)
+		}
+		assert.Equal(t, expectedW, size.Width, "Width should be the sum of object widths and column pads")
+		assert.Equal(t, expectedH, size.Height, "Height should match the tallest object")
+	})
+
+	t.Run("Auto column width shrinks columns to content", func(t *testing.T) {
+		label := NewTextBlock("Notes", TextBlockOpts{})
+		img := loadImageBlock(t, "assets/samples/sample_1.jpg", "Face")
+		labelW, _ := label.IntrinsicSize(ctx, DefaultColWidth, 0)
+		imgW, _ := img.IntrinsicSize(ctx, DefaultColWidth, 0)
+
+		shape := NewShapeWithObjects([]Column{
+			{Objects: []Tileable{label}},
+			{Objects: []Tileable{img}},
+		})
+		pane := NewPaneWithShape(shape, 0, 0, 0)
+		w, _ := pane.IntrinsicSize(ctx, 0, 0)
+		assert.Equal(t, 2*DefaultColWidth+DefaultColPad, w, "Fixed column width should be used by default")
+
+		pane.AutoColWidth = true
+		w, _ = pane.IntrinsicSize(ctx, 0, 0)
+		assert.Equal(t, labelW+imgW+DefaultColPad, w, "Columns should be sized to their widest object")
+		assert.Zero(t, shape.Columns[0].Width, "Measuring should leave the planned shape as set")
+		assert.Zero(t, shape.Columns[1].Width)
+	})
+	t.Run("Equal scores prefer fewer columns", func(t *testing.T) {
+		// one column (100x200) and two columns (200x100) have the same area and aspect ratio
+		pane := &Pane{
+			Objects: []Tileable{
+				&TileProxy{Size: Size{Width: 100, Height: 100}},
+				&TileProxy{Size: Size{Width: 100, Height: 100}},
+			},
+			ColWidth: 100,
+		}
+		shape, _ := pane.Shape(ctx)
+		assert.Len(t, shape.Columns, 1, "Tied layouts should keep the shape with fewer columns")
+
+		score := 40000.0
+		assert.False(t, betterScore(score*(1-1e-12), score), "Scores within epsilon should be treated as ties")
+		assert.True(t, betterScore(score*0.99, score), "Clearly lower scores should win")
+	})
+
+	t.Run("Sequential order keeps contiguous runs per column", func(t *testing.T) {
+		pane := NewPane(objects, 0, 0, 0)
+		pane.OrderMode = OrderSequential
+		shape, _ := pane.Shape(ctx)
+		require.Greater(t, len(shape.Columns), 1)
+		k := len(shape.Columns[0].Objects)
+		require.Greater(t, k, 0)
+		i := 0
+		for _, column := range shape.Columns {
+			for _, obj := range column.Objects {
+				assert.Same(t, objects[i], obj.(*TileProxy).Object, "Object %d should follow column-major order", i)
+				i++
+			}
+		}
+		assert.Equal(t, len(objects), i)
+
+		s := NewShape(3)
+		deriveSequentialShape(ctx, s, objects, DefaultColWidth, DefaultMinPad)
+		for _, column := range s.Columns {
+			assert.Len(t, column.Objects, 2, "Equal height objects should be split evenly")
+		}
+		assert.Equal(t, objects[:2], s.Columns[0].Objects, "The first column should hold the first objects in order")
+	})
+
+	t.Run("Grid rows place objects in row-major order", func(t *testing.T) {
+		pane := NewPane(objects, 0, 0, 0)
+		pane.GridRows = 2
+		shape, _ := pane.Shape(ctx)
+		require.Len(t, shape.Columns, 3, "Six objects in two rows should give three columns")
+		for i, obj := range objects {
+			column := shape.Columns[i%3]
+			require.Greater(t, len(column.Objects), i/3)
+			assert.Same(t, obj, column.Objects[i/3].(*TileProxy).Object, "Object %d should be at row %d, column %d", i, i/3, i%3)
+		}
+	})
+}
+
+func Test_Measure(t *testing.T) {
+	eng := New(Config{
+		MaxCanvasWidth:  4096,
+		MaxCanvasHeight: 4096,
+		FontSize:        32,
+	})
+
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewTextBlock("Imacon: Image Representation of Context Data", TextBlockOpts{TextWrap: true}),
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+		}, 0, 0, 0))
+	}
+
+	w, h, clamped, err := eng.Measure(newScene())
+	require.NoError(t, err)
+	assert.False(t, clamped, "Scene should fit within the max canvas size")
+
+	c, err := eng.Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, c.Width, w, "Measured width should match the rendered canvas width")
+	assert.Equal(t, c.Height, h, "Measured height should match the rendered canvas height")
+
+	small := New(Config{MaxCanvasWidth: w / 2, MaxCanvasHeight: h / 2, FontSize: 32})
+	_, _, clamped, err = small.Measure(newScene())
+	require.NoError(t, err)
+	assert.True(t, clamped, "Scene should be clamped when larger than the max canvas size")
+}
+
+func Test_Encode(t *testing.T) {
+	scene := NewScene(NewPane([]Tileable{
+		loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+	}, 0, 0, 0))
+
+	t.Run("JPEG quality", func(t *testing.T) {
+		var low, high bytes.Buffer
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, DefaultJpegQuality: 10}).Render(scene)
+		require.NoError(t, err)
+		require.NoError(t, c.ToJpeg(&low, nil))
+		c, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, DefaultJpegQuality: 95}).Render(scene)
+		require.NoError(t, err)
+		require.NoError(t, c.ToJpeg(&high, nil))
+		assert.Less(t, low.Len(), high.Len(), "Lower quality JPEG should be smaller")
+	})
+
+	t.Run("PNG compression level", func(t *testing.T) {
+		var fast, best bytes.Buffer
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene)
+		require.NoError(t, err)
+		require.NoError(t, c.ToPngWithLevel(&fast, png.NoCompression))
+		require.NoError(t, c.ToPngWithLevel(&best, png.BestCompression))
+		assert.Less(t, best.Len(), fast.Len(), "Best compression PNG should be smaller than uncompressed")
+	})
+}
+
+func Test_DeterministicRender(t *testing.T) {
+	eng := New(Config{
+		MaxCanvasWidth:  4096,
+		MaxCanvasHeight: 4096,
+		FontSize:        32,
+	})
+
+	newScene := func() *Scene {
+		objects := []Tileable{
+			NewTextBlock("Lorem ipsum dolor sit amet, consectetur adipiscing elit.", TextBlockOpts{TextWrap: true}),
+		}
+		for i := range 5 {
+			objects = append(objects, loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1)))
+		}
+		return NewScene(NewPane(objects, 0, 0, 0))
+	}
+
+	render := func(scene *Scene) []byte {
+		c, err := eng.Render(scene)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, c.ToPng(&buf))
+		return buf.Bytes()
+	}
+
+	first := render(newScene())
+	assert.Equal(t, first, render(newScene()), "Rendering an identical scene should produce identical bytes")
+
+	scene := newScene()
+	assert.Equal(t, render(scene), render(scene), "Re-rendering the same scene should produce identical bytes")
+}
+
+func Test_ShapeMaxAspectRatio(t *testing.T) {
+	// a single tall tile dominates the height, so extra columns don't lower the score and the narrow shape wins
+	newPane := func() *Pane {
+		objects := []Tileable{&solidTile{Size: Size{Width: 100, Height: 1000}, Color: color.Black}}
+		for range 5 {
+			objects = append(objects, &solidTile{Size: Size{Width: 100, Height: 10}, Color: color.Black})
+		}
+		return NewPane(objects, 100, 0, 0)
+	}
+	ctx := gg.NewContext(1, 1)
+
+	_, size := newPane().Shape(ctx)
+	require.Greater(t, size.Height/size.Width, 3.0, "Uncapped layout should be tall and narrow")
+
+	pane := newPane()
+	pane.MaxAspectRatio = 3
+	shape, size := pane.Shape(ctx)
+	assert.LessOrEqual(t, math.Max(size.Width/size.Height, size.Height/size.Width), 3.0, "Capped layout should stay within the aspect ratio")
+	assert.Greater(t, len(shape.Columns), 2)
+
+	t.Run("Falls back when no shape fits", func(t *testing.T) {
+		pane := NewPane([]Tileable{&solidTile{Size: Size{Width: 100, Height: 1000}, Color: color.Black}}, 100, 0, 0)
+		pane.MaxAspectRatio = 3
+		shape, size := pane.Shape(ctx)
+		assert.Len(t, shape.Columns, 1)
+		assert.Equal(t, Size{Width: 100, Height: 1000}, size)
+	})
+}
+
+func Test_ShapeWidthBudget(t *testing.T) {
+	newScene := func() *Scene {
+		objects := make([]Tileable, 8)
+		for i := range objects {
+			objects[i] = loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1))
+		}
+		return NewScene(NewPane(objects, 0, 0, 0))
+	}
+
+	wide := New(Config{MaxCanvasWidth: 8192, MaxCanvasHeight: 8192})
+	w, _, _, err := wide.Measure(newScene())
+	require.NoError(t, err)
+	require.Greater(t, w, 1000, "Unconstrained layout should be wider than the narrow budget")
+
+	narrow := New(Config{MaxCanvasWidth: 1000, MaxCanvasHeight: 8192})
+	scene := newScene()
+	w, _, clamped, err := narrow.Measure(scene)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, w, 1000, "Chosen shape should stay within the max canvas width")
+	assert.False(t, clamped, "Scene should not need downscaling")
+	assert.Len(t, scene.Main.PlannedShape.Columns, 1, "Shape should fall back to the columns that fit the budget")
+}
+
+func Test_ImageBlockLabelWrap(t *testing.T) {
+	img := loadImageBlock(t, "assets/samples/glasses.png", "A pair of round tortoiseshell glasses with thin metal temples, photographed on a plain background")
+	ctx := gg.NewContext(1200, 800)
+	ctx.SetColor(color.White)
+	ctx.Clear()
+	ctx.SetColor(color.Black)
+
+	imgW, imgH := img.IntrinsicSize(ctx, 0, 0)
+	img.Draw(ctx, 1000, imgH)
+
+	// the caption should wrap to the image width and stay within the measured height
+	raw := ctx.Image()
+	for y := 0; y < raw.Bounds().Dy(); y++ {
+		for x := 0; x < raw.Bounds().Dx(); x++ {
+			if float64(x) < imgW && float64(y) < imgH {
+				continue
+			}
+			r, g, b, _ := raw.At(x, y).RGBA()
+			require.True(t, r == 0xffff && g == 0xffff && b == 0xffff, "Pixel (%d, %d) outside the measured bounds should be untouched", x, y)
+		}
+	}
+}
+
+func Test_Thumbnail(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	c, err := eng.Render(NewScene(NewPane([]Tileable{
+		loadImageBlock(t, "assets/samples/sample_2.jpg", "T-shirt"),
+	}, 0, 0, 0)))
+	require.NoError(t, err)
+
+	origW, origH := c.Width, c.Height
+	thumb := c.Thumbnail(200, 200)
+	assert.LessOrEqual(t, thumb.Width, 200, "Thumbnail width should fit within the bounds")
+	assert.LessOrEqual(t, thumb.Height, 200, "Thumbnail height should fit within the bounds")
+	assert.Equal(t, 200, max(thumb.Width, thumb.Height), "Thumbnail should fill the limiting dimension")
+	assert.InDelta(t, float64(origW)/float64(origH), float64(thumb.Width)/float64(thumb.Height), 0.01, "Thumbnail should preserve the aspect ratio")
+	assert.Equal(t, image.Rect(0, 0, thumb.Width, thumb.Height), thumb.Raw.Bounds())
+
+	assert.Equal(t, origW, c.Width, "Original canvas should be untouched")
+	assert.Equal(t, origH, c.Raw.Bounds().Dy(), "Original canvas should be untouched")
+
+	same := c.Thumbnail(origW*2, origH*2)
+	assert.Equal(t, origW, same.Width, "Thumbnail should not scale up")
+	assert.Equal(t, origH, same.Height, "Thumbnail should not scale up")
+}
+
+func Test_CanvasCrop(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(NewPane([]Tileable{NewImageBlockFromImage(img, "")}, 0, 0, 0)))
+	require.NoError(t, err)
+	require.Greater(t, c.Width, 100)
+
+	cropped := c.Crop(color.White)
+	assert.Equal(t, 100, cropped.Width, "Cropping should trim the padding to the content width")
+	assert.Equal(t, 50, cropped.Height, "Cropping should trim the padding to the content height")
+	assert.Equal(t, image.Rect(0, 0, 100, 50), cropped.Raw.Bounds())
+	assert.Equal(t, red, cropped.Raw.At(0, 0))
+	assert.Equal(t, red, cropped.Raw.At(99, 49))
+	assert.Greater(t, c.Width, cropped.Width, "Original canvas should be untouched")
+
+	empty := &Canvas{Width: 10, Height: 10, Raw: image.NewRGBA(image.Rect(0, 0, 10, 10))}
+	assert.Same(t, empty, empty.Crop(nil), "Fully empty canvases should be returned as is")
+}
+
+func Test_CanvasSave(t *testing.T) {
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(NewPane([]Tileable{
+		NewTextBlock("Saved", TextBlockOpts{}),
+	}, 0, 0, 0)))
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	for _, name := range []string{"canvas.png", "canvas.jpg", "canvas.jpeg", "CANVAS.PNG"} {
+		path := dir + "/" + name
+		require.NoError(t, c.Save(path), "Saving %s should succeed", name)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		img, _, err := image.Decode(f)
+		f.Close()
+		require.NoError(t, err, "%s should be decodable", name)
+		assert.Equal(t, c.Raw.Bounds(), img.Bounds())
+	}
+
+	for _, name := range []string{"canvas.webp", "canvas.bmp", "canvas"} {
+		assert.Error(t, c.Save(dir+"/"+name), "Saving %s should fail", name)
+		_, err := os.Stat(dir + "/" + name)
+		assert.True(t, os.IsNotExist(err), "No file should be created for %s", name)
+	}
+}
+
+func Test_RenderProgress(t *testing.T) {
+	var calls [][2]int
+	eng := New(Config{
+		MaxCanvasWidth:  4096,
+		MaxCanvasHeight: 4096,
+		OnProgress: func(done int, total int) {
+			calls = append(calls, [2]int{done, total})
+		},
+	})
+
+	objects := []Tileable{
+		NewTextBlock("Progress", TextBlockOpts{}),
+		// nested objects are drawn as part of their top-level pane
+		NewPane([]Tileable{
+			NewTextBlock("Nested 1", TextBlockOpts{}),
+			NewTextBlock("Nested 2", TextBlockOpts{}),
+		}, 0, 0, 0),
+	}
+	for i := range 3 {
+		objects = append(objects, loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1)))
+	}
+	_, err := eng.Render(NewScene(NewPane(objects, 0, 0, 0)))
+	require.NoError(t, err)
+
+	require.Len(t, calls, len(objects), "Callback should fire once per top-level object")
+	for i, call := range calls {
+		assert.Equal(t, i+1, call[0], "Done count should increase monotonically")
+		assert.Equal(t, len(objects), call[1], "Total should be the top-level object count")
+	}
+}
+
+// solidTile is a test tileable that fills its intrinsic size with a single color.
+type solidTile struct {
+	Size  Size
+	Color color.Color
+}
+
+func (s *solidTile) Draw(ctx *gg.Context, cw float64, ch float64) {
+	ctx.Push()
+	ctx.SetColor(s.Color)
+	ctx.DrawRectangle(0, 0, s.Size.Width, s.Size.Height)
+	ctx.Fill()
+	ctx.Pop()
+}
+
+func (s *solidTile) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return s.Size.Width, s.Size.Height
+}
+
+func Test_Overlays(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	scene := NewScene(NewPane([]Tileable{
+		loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+	}, 0, 0, 0))
+	scene.Overlays = []PositionedDrawable{
+		{Object: &solidTile{Size: Size{Width: 20, Height: 20}, Color: red}, X: 100, Y: 50},
+		{Object: &solidTile{Size: Size{Width: 20, Height: 20}, Color: red}, X: 300, Y: 300, AnchorX: 0.5, AnchorY: 0.5},
+	}
+	widthBefore, heightBefore, _, err := eng.Measure(NewScene(NewPane([]Tileable{
+		loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+	}, 0, 0, 0)))
+	require.NoError(t, err)
+
+	c, err := eng.Render(scene)
+	require.NoError(t, err)
+	assert.Equal(t, widthBefore, c.Width, "Overlays should not affect the layout")
+	assert.Equal(t, heightBefore, c.Height, "Overlays should not affect the layout")
+
+	// top-left anchored overlay
+	assert.Equal(t, color.RGBAModel.Convert(red), c.Raw.At(100, 50))
+	assert.Equal(t, color.RGBAModel.Convert(red), c.Raw.At(119, 69))
+	assert.NotEqual(t, color.RGBAModel.Convert(red), c.Raw.At(120, 70))
+
+	// center anchored overlay
+	assert.Equal(t, color.RGBAModel.Convert(red), c.Raw.At(290, 290))
+	assert.Equal(t, color.RGBAModel.Convert(red), c.Raw.At(309, 309))
+	assert.NotEqual(t, color.RGBAModel.Convert(red), c.Raw.At(289, 289))
+}
+
+func Test_OutputScale(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewTextBlock("Imacon: Image Representation of Context Data", TextBlockOpts{TextWrap: true}),
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+		}, 0, 0, 0))
+	}
+
+	c1, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(newScene())
+	require.NoError(t, err)
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Scale: 2})
+	c2, err := eng.Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, c1.Width*2, c2.Width, "Scale=2 should double the canvas width")
+	assert.Equal(t, c1.Height*2, c2.Height, "Scale=2 should double the canvas height")
+	assert.Equal(t, image.Rect(0, 0, c2.Width, c2.Height), c2.Raw.Bounds())
+
+	w, h, _, err := eng.Measure(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, c2.Width, w, "Measure should report scaled dimensions")
+	assert.Equal(t, c2.Height, h, "Measure should report scaled dimensions")
+
+	// max canvas dimensions are in logical units
+	clampedEng := New(Config{MaxCanvasWidth: c1.Width / 2, MaxCanvasHeight: 4096, Scale: 2})
+	c3, err := clampedEng.Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, (c1.Width/2)*2, c3.Width, "Clamping should apply before scaling")
+
+	_, err = New(Config{Scale: -1}).Render(newScene())
+	assert.ErrorContains(t, err, "scale must not be negative")
+}
+
+func Test_ScaleSharpText(t *testing.T) {
+	// crispness returns the share of the text's ink that is solid rather than a blurred edge
+	crispness := func(cfg Config) float64 {
+		c, err := New(cfg).Render(NewScene(NewPane([]Tileable{
+			NewTextBlock("Sharp *text*", TextBlockOpts{Markdown: true}),
+			NewBadge("Badge", nil, nil, 0),
+		}, 0, 0, 0)))
+		require.NoError(t, err)
+		ink, solid := 0, 0
+		for y := range c.Height {
+			for x := range c.Width {
+				r, g, b, _ := c.Raw.At(x, y).RGBA()
+				if r != g || g != b || r == 0xffff {
+					continue
+				}
+				ink++
+				if r < 0x2000 {
+					solid++
+				}
+			}
+		}
+		return float64(solid) / float64(ink)
+	}
+	unscaled := crispness(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: 12})
+	scaled := crispness(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: 12, Scale: 3})
+	assert.Greater(t, scaled, unscaled*2, "Glyphs should be rasterized at the scaled size rather than upscaled")
+}
+
+func Test_ImageBlockPlaceholder(t *testing.T) {
+	t.Run("Broken reader yields a placeholder", func(t *testing.T) {
+		block := NewImageBlockOrPlaceholder(bytes.NewReader([]byte("not an image")), "Broken")
+		require.NotNil(t, block)
+		assert.Equal(t, image.Rect(0, 0, DefaultPlaceholderWidth, DefaultPlaceholderHeight), block.Image.Bounds())
+		assert.Equal(t, color.RGBAModel.Convert(PlaceholderColor), block.Image.At(10, 10))
+		assert.Equal(t, "Broken", block.Label.Text)
+	})
+
+	t.Run("Valid reader decodes the image", func(t *testing.T) {
+		f, err := os.Open("assets/samples/sample_1.jpg")
+		require.NoError(t, err)
+		defer f.Close()
+		block := NewImageBlockOrPlaceholder(f, "Face")
+		assert.Equal(t, 485, block.Image.Bounds().Dx())
+	})
+
+	t.Run("Scene renders with a broken image", func(t *testing.T) {
+		eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+		_, err := eng.Render(NewScene(NewPane([]Tileable{
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+			NewImageBlockOrPlaceholder(bytes.NewReader(nil), "Broken"),
+		}, 0, 0, 0)))
+		assert.NoError(t, err)
+	})
+}
+
+func Test_ImageBlockFormats(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	encoders := map[string]func(w io.Writer, m image.Image) error{
+		"GIF":  func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) },
+		"BMP":  bmp.Encode,
+		"TIFF": func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) },
+	}
+	for name, encode := range encoders {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encode(&buf, img))
+			block, err := NewImageBlock(&buf, name)
+			require.NoError(t, err)
+			assert.Equal(t, img.Bounds(), block.Image.Bounds())
+			r, g, b, _ := block.Image.At(10, 10).RGBA()
+			assert.Equal(t, [3]uint32{0xffff, 0, 0}, [3]uint32{r, g, b}, "Decoded pixels should match the source image")
+		})
+	}
+}
+
+func Test_CanvasLayout(t *testing.T) {
+	objects := make([]Tileable, 6)
+	for i := range objects {
+		objects[i] = loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1))
+	}
+	objects = append(objects, NewTextBlock("A short caption", TextBlockOpts{TextWrap: true}))
+
+	for _, cfg := range []Config{
+		{MaxCanvasWidth: 8192, MaxCanvasHeight: 8192},
+		{MaxCanvasWidth: 600, MaxCanvasHeight: 600},
+	} {
+		t.Run(fmt.Sprintf("%dx%d", cfg.MaxCanvasWidth, cfg.MaxCanvasHeight), func(t *testing.T) {
+			c, err := New(cfg).Render(NewScene(NewPane(objects, 0, 0, 0)))
+			require.NoError(t, err)
+			require.NotNil(t, c.Layout)
+
+			var seen []Tileable
+			var bounds []image.Rectangle
+			for i, column := range c.Layout.Shape.Columns {
+				require.Len(t, c.Layout.Bounds[i], len(column.Objects))
+				seen = append(seen, column.Objects...)
+				bounds = append(bounds, c.Layout.Bounds[i]...)
+			}
+			assert.ElementsMatch(t, objects, seen, "Layout should hold each of the pane's original objects once")
+
+			canvas := image.Rect(0, 0, c.Width, c.Height)
+			for i, b := range bounds {
+				assert.False(t, b.Empty())
+				assert.True(t, b.In(canvas), "Bounds %v should be within the canvas", b)
+				for _, other := range bounds[i+1:] {
+					assert.False(t, b.Overlaps(other), "Bounds %v and %v should not overlap", b, other)
+				}
+			}
+		})
+	}
+}
+
+func Test_RenderWithRegions(t *testing.T) {
+	img := loadImageBlock(t, "assets/samples/glasses.png", "Glasses")
+	caption := NewTextBlock("Caption", TextBlockOpts{})
+	nested := NewPane([]Tileable{caption}, 0, 0, 0)
+	badge := NewBadge("New", color.White, color.Black, 4)
+	scene := NewScene(NewPane([]Tileable{img, nested}, 0, 0, 0))
+	scene.Main.Layout = LayoutStack
+	scene.Overlays = []PositionedDrawable{{Object: badge, X: 10, Y: 10}}
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Scale: 2})
+	result, err := eng.RenderWithRegions(scene)
+	require.NoError(t, err)
+	require.Contains(t, result.Regions, img)
+	assert.Contains(t, result.Regions, nested)
+	assert.Contains(t, result.Regions, caption, "Objects of nested panes should be recorded")
+	assert.Contains(t, result.Regions, badge, "Tileable overlays should be recorded")
+
+	// the image block is drawn first in the stack, right inside the outer padding
+	ctx := gg.NewContext(1024, 1024)
+	face, err := eng.fontFace()
+	require.NoError(t, err)
+	ctx.SetFontFace(face)
+	w, h := img.IntrinsicSize(ctx, DefaultColWidth, 0)
+	region := result.Regions[img]
+	assert.Equal(t, image.Pt(2*DefaultOuterPad, 2*DefaultOuterPad), region.Min, "Region should start at the drawn position")
+	assert.InDelta(t, 2*w, float64(region.Dx()), 1, "Region should cover the scaled image block width")
+	assert.InDelta(t, 2*h, float64(region.Dy()), 1, "Region should cover the scaled image block height")
+	assert.False(t, region.Overlaps(result.Regions[nested]))
+	assert.True(t, result.Regions[nested].In(image.Rect(0, 0, result.Canvas.Width, result.Canvas.Height)))
+}
+
+func Test_SceneHeader(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	title := NewTextBlock("Character Sheet", TextBlockOpts{FontSize: 32})
+	newScene := func() *Scene {
+		images := make([]Tileable, 4)
+		for i := range images {
+			images[i] = loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1))
+		}
+		return NewScene(NewPane(images, 0, 0, 0))
+	}
+
+	_, plainH, _, err := eng.Measure(newScene())
+	require.NoError(t, err)
+
+	scene := newScene()
+	scene.Header = NewPane([]Tileable{title}, 0, 0, 0)
+	_, h, _, err := eng.Measure(scene)
+	require.NoError(t, err)
+	ctx := gg.NewContext(1, 1)
+	face, err := newFontFace(32)
+	require.NoError(t, err)
+	ctx.SetFontFace(face)
+	_, titleH := title.IntrinsicSize(ctx, DefaultColWidth, 0)
+	assert.Equal(t, plainH+int(titleH+DefaultBandPad), h, "Canvas height should include the header band")
+
+	result, err := eng.RenderWithRegions(scene)
+	require.NoError(t, err)
+	titleRegion := result.Regions[title]
+	assert.Equal(t, int(DefaultOuterPad), titleRegion.Min.Y, "Header should be drawn at the top of the canvas")
+	for _, column := range result.Canvas.Layout.Bounds {
+		for _, b := range column {
+			assert.GreaterOrEqual(t, b.Min.Y, titleRegion.Max.Y, "Main pane objects should be drawn below the header")
+		}
+	}
+}
+
+func Test_SceneFooter(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	attribution := NewTextBlock("Generated by imacon", TextBlockOpts{})
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+			loadImageBlock(t, "assets/samples/glasses.png", "Glasses"),
+		}, 0, 0, 0))
+	}
+
+	_, plainH, _, err := eng.Measure(newScene())
+	require.NoError(t, err)
+
+	scene := newScene()
+	scene.Footer = NewPane([]Tileable{attribution}, 0, 0, 0)
+	scene.FooterDivider = NewDivider(2, color.RGBA{R: 255, A: 255})
+	result, err := eng.RenderWithRegions(scene)
+	require.NoError(t, err)
+	c := result.Canvas
+
+	ctx := gg.NewContext(1, 1)
+	face, err := eng.fontFace()
+	require.NoError(t, err)
+	ctx.SetFontFace(face)
+	_, footerH := attribution.IntrinsicSize(ctx, DefaultColWidth, 0)
+	assert.InDelta(t, plainH+int(footerH+2+2*DefaultBandPad), c.Height, 1, "Canvas height should include the footer band and divider")
+
+	footerRegion := result.Regions[attribution]
+	assert.InDelta(t, c.Height-int(DefaultOuterPad), footerRegion.Max.Y, 1, "Footer should be drawn at the bottom of the canvas")
+	for _, column := range c.Layout.Bounds {
+		for _, b := range column {
+			assert.LessOrEqual(t, b.Max.Y, footerRegion.Min.Y, "Main pane objects should be drawn above the footer")
+		}
+	}
+	dividerY := footerRegion.Min.Y - int(DefaultBandPad) - 1
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, c.Raw.At(c.Width/2, dividerY), "Divider should be drawn between the content and the footer")
+}
+
+func Test_ContentAlign(t *testing.T) {
+	// the wide tile is downscaled to fit the width, leaving empty space below it
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{&solidTile{Size: Size{Width: 2000, Height: 500}, Color: color.Black}}, 2000, 0, 0))
+	}
+	// tileRows returns the first and last rows covered by the tile along the center column
+	tileRows := func(c *Canvas) (int, int) {
+		first, last := -1, -1
+		for y := range c.Height {
+			if r, _, _, _ := c.Raw.At(c.Width/2, y).RGBA(); r < 0x8000 {
+				if first < 0 {
+					first = y
+				}
+				last = y
+			}
+		}
+		return first, last
+	}
+
+	c, err := New(Config{MaxCanvasWidth: 1000, MaxCanvasHeight: 1000}).Render(newScene())
+	require.NoError(t, err)
+	first, last := tileRows(c)
+	assert.Less(t, first, c.Height-1-last, "Top-left alignment should leave the empty space at the bottom")
+
+	c, err = New(Config{MaxCanvasWidth: 1000, MaxCanvasHeight: 1000, ContentAlign: AlignCenter}).Render(newScene())
+	require.NoError(t, err)
+	first, last = tileRows(c)
+	assert.InDelta(t, first, c.Height-1-last, 1, "Centered alignment should leave equal margins above and below")
+}
+
+func Test_ColWidthPct(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 1000 + 2*DefaultOuterPad, MaxCanvasHeight: 8192})
+	pane := NewPane([]Tileable{loadImageBlock(t, "assets/samples/sample_1.jpg", "Face")}, 0, 0, 0)
+	pane.ColWidthPct = 0.5
+
+	w, _, _, err := eng.Measure(NewScene(pane))
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, pane.colWidth(gg.NewContext(1, 1)), "Column width should be half of the available canvas width")
+	assert.Equal(t, 500+2*DefaultOuterPad, float64(w), "The single column should span half of the available width")
+}
+
+func Test_ShrinkColWidth(t *testing.T) {
+	newPane := func() *Pane {
+		objects := make([]Tileable, 6)
+		for i := range objects {
+			objects[i] = NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 200, 150)), "")
+		}
+		return NewPane(objects, 0, 0, 0)
+	}
+	ctx := gg.NewContext(1, 1)
+
+	pane := newPane()
+	shape, _ := pane.Shape(ctx)
+	assert.Equal(t, DefaultColWidth, shape.Columns[0].EffectiveWidth(pane.colWidth(ctx)), "Columns should follow the default width")
+
+	pane = newPane()
+	pane.ShrinkColWidth = true
+	shape, size := pane.Shape(ctx)
+	assert.Equal(t, 200.0, pane.colWidth(ctx), "The column width should shrink to the widest object")
+	cols := float64(len(shape.Columns))
+	assert.Equal(t, cols*200+(cols-1)*pane.ColPad, size.Width, "The canvas should be sized from the shrunk columns")
+
+	pane.ColWidth = 150
+	pane.Shape(ctx)
+	assert.Equal(t, 150.0, pane.colWidth(ctx), "The shrunk width should be capped at the configured width")
+}
+
+func Test_DebugGrid(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewTextBlock("Hello", TextBlockOpts{}),
+			NewTextBlock("World", TextBlockOpts{}),
+		}, 200, 0, 0))
+	}
+
+	plain, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(newScene())
+	require.NoError(t, err)
+	debug, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, DebugGrid: true}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, plain.Width, debug.Width, "Debug grid should not affect measurement")
+	assert.Equal(t, plain.Height, debug.Height, "Debug grid should not affect measurement")
+
+	// the left edge of the first column and the right edge of the column box
+	y := debug.Height / 2
+	for _, x := range []int{int(DefaultOuterPad), int(DefaultOuterPad) + 199} {
+		assert.Equal(t, color.RGBA{255, 255, 255, 255}, plain.Raw.At(x, y))
+		r, g, b, _ := debug.Raw.At(x, y).RGBA()
+		assert.True(t, r > g && b > g, "Column boundary at x=%d should be stroked in the debug color", x)
+	}
+}
+
+func Test_SceneWithoutMainPane(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	_, err := eng.Render(&Scene{})
+	assert.EqualError(t, err, "imacon: scene has no Main pane")
+	_, _, _, err = eng.Measure(&Scene{})
+	assert.Error(t, err)
+	assert.Error(t, eng.RenderTo(&Scene{}, io.Discard, FormatPNG))
+	_, err = eng.RenderPaginated(&Scene{}, 4)
+	assert.Error(t, err)
+}
+
+func Test_MaxCanvasSizeValidation(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{loadImageBlock(t, "assets/samples/sample_1.jpg", "Face")}, 0, 0, 0))
+	}
+	w, h, _, err := New(Config{MaxCanvasWidth: 8192, MaxCanvasHeight: 8192}).Measure(newScene())
+	require.NoError(t, err)
+
+	c, err := New(Config{}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, w, c.Width, "Zero max width should render at the full intrinsic width")
+	assert.Equal(t, h, c.Height, "Zero max height should render at the full intrinsic height")
+
+	c, err = New(Config{MaxCanvasWidth: 8192}).Render(newScene())
+	require.NoError(t, err)
+	assert.Equal(t, h, c.Height, "Zero max height alone should not clamp the canvas")
+
+	_, err = New(Config{MaxCanvasWidth: -1, MaxCanvasHeight: 100}).Render(newScene())
+	assert.Error(t, err, "Negative max dimensions should be rejected")
+}
+
+func Benchmark_RenderRepeated(b *testing.B) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	scene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewTextBlock("Hello, World!", TextBlockOpts{}),
+			NewTextBlock("A short paragraph of text that is wrapped within the column width.", TextBlockOpts{TextWrap: true}),
+		}, 200, 0, 0))
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, _, err := eng.Measure(scene()); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := eng.Render(scene()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_NewImageBlockFromImage(t *testing.T) {
+	data, err := os.ReadFile("assets/samples/glasses.png")
+	require.NoError(t, err)
+	img, _, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	fromReader, err := NewImageBlock(bytes.NewReader(data), "Glasses")
+	require.NoError(t, err)
+	fromImage := NewImageBlockFromImage(img, "Glasses")
+	assert.Equal(t, fromReader, fromImage, "Blocks from a reader and a decoded image of the same source should be equivalent")
+
+	ctx := gg.NewContext(1, 1)
+	w, h := fromReader.IntrinsicSize(ctx, 300, 0)
+	iw, ih := fromImage.IntrinsicSize(ctx, 300, 0)
+	assert.Equal(t, w, iw)
+	assert.Equal(t, h, ih)
+}
+
+func Test_NewImageBlockFromBytes(t *testing.T) {
+	for _, path := range []string{"assets/samples/sample_1.jpg", "assets/samples/glasses.png"} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		fromBytes, err := NewImageBlockFromBytes(data, "Sample")
+		require.NoError(t, err, path)
+		fromReader, err := NewImageBlock(bytes.NewReader(data), "Sample")
+		require.NoError(t, err)
+		assert.Equal(t, fromReader, fromBytes, "Blocks from bytes and a reader of the same data should be equivalent")
+	}
+
+	_, err := NewImageBlockFromBytes([]byte("not an image"), "Broken")
+	assert.ErrorIs(t, err, image.ErrFormat, "Undecodable data should fail like the reader path")
+	_, err = NewImageBlockFromBytes(nil, "Empty")
+	assert.Error(t, err)
+}
+
+func Test_ImageBlockMaxHeight(t *testing.T) {
+	tall := image.NewRGBA(image.Rect(0, 0, 100, 2000))
+	block := NewImageBlockFromImage(tall, "Tall")
+	block.Opts.MaxHeight = 300
+	ctx := gg.NewContext(1, 1)
+	_, labelH := block.Label.IntrinsicSize(ctx, 15, 0)
+
+	w, h := block.IntrinsicSize(ctx, 720, 0)
+	assert.Equal(t, 15.0, w, "Width should shrink with the capped height to keep the aspect ratio")
+	assert.Equal(t, 300+labelH+DefaultLabelPad, h, "Height should be capped at the max height plus the label")
+
+	_, h = block.IntrinsicSize(ctx, 50, 0)
+	assert.Equal(t, 300+labelH+DefaultLabelPad, h)
+
+	block.Opts.MaxHeight = 0
+	_, h = block.IntrinsicSize(ctx, 0, 0)
+	assert.Greater(t, h, 2000.0, "Images should not be capped without a max height")
+}
+
+func Test_ImageBlockMaxDisplayWidth(t *testing.T) {
+	huge := image.NewRGBA(image.Rect(0, 0, 4000, 2000))
+	draw.Draw(huge, huge.Bounds(), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	block := NewImageBlockFromImage(huge, "")
+	block.Opts.MaxDisplayWidth = 600
+	ctx := gg.NewContext(1, 1)
+
+	for _, cw := range []float64{0, 1200, 5000} {
+		w, h := block.IntrinsicSize(ctx, cw, 0)
+		assert.Equal(t, 600.0, w, "Width should be capped at the max display width in a %v column", cw)
+		assert.Equal(t, 300+DefaultLabelPad, h, "Height should shrink with the capped width")
+	}
+	w, _ := block.IntrinsicSize(ctx, 400, 0)
+	assert.Equal(t, 400.0, w, "Narrower columns should still scale the image down further")
+
+	// the drawn image ends at the capped width, even in a wider column
+	ctx = gg.NewContext(1200, 400)
+	block.Draw(ctx, 1200, 400)
+	_, _, _, a := ctx.Image().At(599, 150).RGBA()
+	assert.NotZero(t, a)
+	_, _, _, a = ctx.Image().At(601, 150).RGBA()
+	assert.Zero(t, a)
+}
+
+func Test_ImageBlockTopLabel(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	block := NewImageBlockFromImage(red, "After")
+	ctx := gg.NewContext(1, 1)
+	_, plain := block.IntrinsicSize(ctx, 0, 0)
+
+	block.Opts.TopLabel = "Before"
+	_, h := block.IntrinsicSize(ctx, 0, 0)
+	_, topH := NewTextBlock("Before", TextBlockOpts{TextWrap: true}).IntrinsicSize(ctx, 200, 0)
+	assert.Equal(t, plain+topH+DefaultLabelPad, h, "The height should include both labels")
+
+	// the image starts below the top label
+	ctx = gg.NewContext(200, int(h))
+	block.Draw(ctx, 200, h)
+	top := int(topH + DefaultLabelPad)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, ctx.Image().At(100, top+1), "The image should be drawn below the top label")
+	_, _, _, a := ctx.Image().At(100, top-2).RGBA()
+	assert.Zero(t, a, "The gap above the image should be empty")
+}
+
+func Test_ImageBlockCropAspect(t *testing.T) {
+	block := loadImageBlock(t, "assets/samples/sample_2.jpg", "") // 819x1024
+	block.Opts.CropAspect = 1
+	ctx := gg.NewContext(1, 1)
+
+	w, h := block.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 819.0, w)
+	assert.Equal(t, 819.0+DefaultLabelPad, h, "The image should be cropped to a square")
+	assert.Equal(t, image.Pt(819, 819), block.source().Bounds().Size())
+	assert.Equal(t, image.Pt(819, 1024), block.Image.Bounds().Size(), "The original image should be kept")
+
+	// the crop is centered, taking the middle rows of the image
+	want := color.RGBAModel.Convert(block.Image.At(400, (1024-819)/2+10))
+	assert.Equal(t, want, block.source().At(400, 10))
+
+	block.Opts.CropAspect = 2
+	w, h = block.IntrinsicSize(ctx, 400, 0)
+	assert.Equal(t, 400.0, w)
+	assert.InDelta(t, 200+DefaultLabelPad, h, 0.5, "The block should follow a changed aspect ratio")
+}
+
+func Test_ImageBlockLabelPad(t *testing.T) {
+	block := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 200, 100)), "Padded")
+	ctx := gg.NewContext(1, 1)
+	_, h := block.IntrinsicSize(ctx, 0, 0)
+
+	block.Opts.LabelPad = 20
+	_, padded := block.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, h+20-DefaultLabelPad, padded, "A larger label pad should increase the height by the difference")
+
+	// the label should start right below the configured gap
+	ctx = gg.NewContext(200, int(math.Ceil(padded)))
+	ctx.SetColor(color.Black)
+	block.Draw(ctx, 200, padded)
+	img := ctx.Image()
+	for y := 100; y < 120; y++ {
+		for x := range 200 {
+			_, _, _, a := img.At(x, y).RGBA()
+			assert.Zero(t, a, "Nothing should be drawn within the label pad at (%d, %d)", x, y)
+		}
+	}
+}
+
+func Test_ImageBlockCaptionMaxLines(t *testing.T) {
+	f, err := os.Open("assets/samples/glasses.png")
+	require.NoError(t, err)
+	defer f.Close()
+	caption := strings.Repeat("a_very_long_file_name ", 20) + "final.png"
+	block, err := NewImageBlockWithLabelOpts(f, caption, TextBlockOpts{TextWrap: true, MaxLines: 2})
+	require.NoError(t, err)
+
+	face, err := newFontFace(DefaultMinFontSize)
+	require.NoError(t, err)
+	ctx := gg.NewContext(1, 1)
+	ctx.SetFontFace(face)
+	width := 200.0
+
+	lines := block.Label.wrap(ctx, width)
+	require.Len(t, lines, 2, "The caption should be clamped to the max lines")
+	assert.True(t, strings.HasSuffix(lines[1], ellipsis), "The last line should end with an ellipsis")
+	assert.LessOrEqual(t, block.Label.measureLine(ctx, lines[1]), width, "The ellipsized line should fit the width")
+
+	_, h := block.Label.IntrinsicSize(ctx, width, 0)
+	assert.Equal(t, textHeight(ctx, 2), h, "The reserved caption height should cover only the max lines")
+
+	block.Label.Opts.MaxLines = 0
+	assert.Greater(t, len(block.Label.wrap(ctx, width)), 2, "Captions should not be clamped without max lines")
+}
+
+func Test_ImageBlockLabelOpts(t *testing.T) {
+	open := func() *os.File {
+		f, err := os.Open("assets/samples/glasses.png")
+		require.NoError(t, err)
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	red := color.RGBA{R: 255, A: 255}
+	plain, err := NewImageBlock(open(), "Glasses")
+	require.NoError(t, err)
+	centered, err := NewImageBlockWithLabelOpts(open(), "Glasses", TextBlockOpts{TextWrap: true, HAlign: HAlignCenter, Color: red})
+	require.NoError(t, err)
+
+	// captionInk returns the leftmost column of the caption below the image, and whether it is drawn in red
+	captionInk := func(block *ImageBlock) (int, bool) {
+		ctx := gg.NewContext(1, 1)
+		w, h := block.IntrinsicSize(ctx, 0, 0)
+		ctx = gg.NewContext(int(w), int(h))
+		ctx.SetColor(color.Black)
+		block.Draw(ctx, w, h)
+		img := ctx.Image()
+		top := block.Image.Bounds().Dy() + int(DefaultLabelPad)
+		for x := range int(w) {
+			for y := top; y < int(h); y++ {
+				if r, _, _, a := img.At(x, y).RGBA(); a > 0x8000 {
+					return x, r > 0x8000
+				}
+			}
+		}
+		return -1, false
+	}
+
+	plainX, plainRed := captionInk(plain)
+	centeredX, centeredRed := captionInk(centered)
+	assert.Less(t, plainX, 5, "Default caption should be left-aligned")
+	assert.Greater(t, centeredX, plainX+50, "Centered caption should be drawn further right")
+	assert.False(t, plainRed)
+	assert.True(t, centeredRed, "Caption should be drawn in the label color")
+}
+
+func Test_ImageBlockHAlign(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	narrow := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(narrow, narrow.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+
+	// leftEdge returns the leftmost red column of the image row in a 400 pixel wide column
+	leftEdge := func(align HAlign) int {
+		block := NewImageBlockFromImage(narrow, "")
+		block.Opts.HAlign = align
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(NewPane([]Tileable{block}, 400, 0, 0)))
+		require.NoError(t, err)
+		y := int(DefaultOuterPad) + 25
+		for x := range c.Width {
+			if c.Raw.At(x, y) == red {
+				return x - int(DefaultOuterPad)
+			}
+		}
+		return -1
+	}
+
+	assert.Equal(t, 0, leftEdge(HAlignLeft), "Images should be left-aligned by default")
+	assert.Equal(t, 150, leftEdge(HAlignCenter), "Centered image should be offset by half of the free column width")
+	assert.Equal(t, 300, leftEdge(HAlignRight), "Right-aligned image should be offset by the free column width")
+
+	block := NewImageBlockFromImage(narrow, "")
+	w, _ := block.IntrinsicSize(gg.NewContext(1, 1), 400, 0)
+	block.Opts.HAlign = HAlignCenter
+	cw, _ := block.IntrinsicSize(gg.NewContext(1, 1), 400, 0)
+	assert.Equal(t, w, cw, "Alignment should not affect the intrinsic size")
+}
+
+func Test_DrawOnto(t *testing.T) {
+	solid := func(c color.Color, w int, h int) *ImageBlock {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		return NewImageBlockFromImage(img, "")
+	}
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	left := NewScene(NewPane([]Tileable{solid(color.RGBA{R: 255, A: 255}, 120, 80)}, 0, 0, 0))
+	right := NewScene(NewPane([]Tileable{solid(color.RGBA{B: 255, A: 255}, 60, 100)}, 0, 0, 0))
+
+	leftCanvas, err := eng.Render(left)
+	require.NoError(t, err)
+	rightCanvas, err := eng.Render(right)
+	require.NoError(t, err)
+
+	ctx := gg.NewContext(leftCanvas.Width+rightCanvas.Width, max(leftCanvas.Height, rightCanvas.Height))
+	require.NoError(t, eng.DrawOnto(ctx, left, 0, 0))
+	require.NoError(t, eng.DrawOnto(ctx, right, float64(leftCanvas.Width), 0))
+	composite := ctx.Image()
+
+	// each scene should be drawn exactly as rendered on its own, at its offset
+	for _, tc := range []struct {
+		canvas *Canvas
+		x      int
+	}{{leftCanvas, 0}, {rightCanvas, leftCanvas.Width}} {
+		for y := range tc.canvas.Height {
+			for x := range tc.canvas.Width {
+				if !assert.Equal(t, tc.canvas.Raw.At(x, y), composite.At(tc.x+x, y), "Pixel (%d, %d) should match the rendered scene", tc.x+x, y) {
+					return
+				}
+			}
+		}
+	}
+	if leftCanvas.Height < rightCanvas.Height {
+		_, _, _, a := composite.At(0, leftCanvas.Height).RGBA()
+		assert.Zero(t, a, "Pixels outside of the drawn scenes should be left untouched")
+	}
+
+	assert.Error(t, eng.DrawOnto(ctx, &Scene{}, 0, 0), "Scenes without a main pane should be rejected")
+}
+
+func Test_TargetCharsPerLine(t *testing.T) {
+	face, err := newFontFace(DefaultMinFontSize)
+	require.NoError(t, err)
+	ctx := gg.NewContext(1, 1)
+	ctx.SetFontFace(face)
+
+	text := NewTextBlock(strings.Repeat("lorem ipsum dolor sit amet ", 20), TextBlockOpts{TextWrap: true})
+	pane := NewPane([]Tileable{text}, 0, 0, 0)
+	pane.TargetCharsPerLine = 60
+	_, size := pane.Shape(ctx)
+
+	width := pane.colWidth(ctx)
+	advance, _ := ctx.MeasureString("0")
+	assert.InDelta(t, 60*advance, width, 0.001, "Column width should fit the target characters of the font")
+	assert.Equal(t, width, size.Width, "The shape should be laid out with the derived column width")
+
+	lines := text.wrap(ctx, width)
+	require.Greater(t, len(lines), 1)
+	for _, line := range lines[:len(lines)-1] {
+		assert.LessOrEqual(t, len(line), 60, "Lines should fit the target character count")
+		assert.Greater(t, len(line), 50, "Lines should be close to the target character count")
+	}
+}
+
+// slowTile is a tileable that takes a while to draw, counting how often it is drawn.
+// blockingTile stands for an object that is slow to measure or draw: the first of its calls blocks until release is closed.
+type blockingTile struct {
+	blockMeasure bool
+	release      chan struct{}
+	once         *sync.Once
+	measures     *atomic.Int32
+	draws        *atomic.Int32
+}
+
+func newBlockingTiles(n int, blockMeasure bool) ([]Tileable, blockingTile) {
+	tile := blockingTile{
+		blockMeasure: blockMeasure,
+		release:      make(chan struct{}),
+		once:         &sync.Once{},
+		measures:     &atomic.Int32{},
+		draws:        &atomic.Int32{},
+	}
+	tiles := make([]Tileable, n)
+	for i := range tiles {
+		tiles[i] = tile
+	}
+	return tiles, tile
+}
+
+// block waits for release on the first call of any of the tiles
+func (b blockingTile) block() {
+	b.once.Do(func() { <-b.release })
+}
+
+func (b blockingTile) Draw(ctx *gg.Context, cw float64, ch float64) {
+	b.draws.Add(1)
+	if !b.blockMeasure {
+		b.block()
+	}
+}
+
+func (b blockingTile) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	b.measures.Add(1)
+	if b.blockMeasure {
+		b.block()
+	}
+	return 50, 50
+}
+
+func Test_RenderTimeout(t *testing.T) {
+	var progress atomic.Int32
+	newEngine := func() *Engine {
+		return New(Config{
+			MaxCanvasWidth:  4096,
+			MaxCanvasHeight: 4096,
+			Timeout:         50 * time.Millisecond,
+			OnProgress:      func(done int, total int) { progress.Add(1) },
+		})
+	}
+
+	t.Run("While drawing", func(t *testing.T) {
+		objects, tile := newBlockingTiles(5, false)
+		scene := NewScene(NewPane(objects, 0, 0, 0))
+		c, err := newEngine().Render(scene)
+		assert.ErrorIs(t, err, ErrRenderTimeout)
+		assert.Nil(t, c, "The partial canvas should be discarded")
+
+		// the abandoned render finishes the blocked object and stops before drawing the next one
+		close(tile.release)
+		time.Sleep(50 * time.Millisecond)
+		assert.LessOrEqual(t, tile.draws.Load(), int32(1), "No further objects should be drawn after the timeout")
+		assert.Zero(t, progress.Load(), "Progress should not be reported after the timeout")
+	})
+
+	t.Run("While laying out", func(t *testing.T) {
+		objects, tile := newBlockingTiles(5, true)
+		scene := NewScene(NewPane(objects, 0, 0, 0))
+		c, err := newEngine().Render(scene)
+		assert.ErrorIs(t, err, ErrRenderTimeout)
+		assert.Nil(t, c)
+
+		close(tile.release)
+		time.Sleep(50 * time.Millisecond)
+		assert.LessOrEqual(t, tile.measures.Load(), int32(1), "No further objects should be measured after the timeout")
+		assert.Zero(t, tile.draws.Load())
+		assert.Nil(t, scene.Main.PlannedShape, "The abandoned layout should not be written onto the scene")
+		assert.Zero(t, progress.Load())
+	})
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Timeout: 10 * time.Second})
+	_, err := eng.Render(NewScene(NewPane([]Tileable{NewTextBlock("Fast", TextBlockOpts{})}, 0, 0, 0)))
+	assert.NoError(t, err, "Renders within the timeout should succeed")
+}
+
+func Test_PaneBackground(t *testing.T) {
+	blank := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 100, 100)), "")
+	card := NewPane([]Tileable{blank}, 100, 0, 0)
+	card.Background = color.NRGBA{R: 255, A: 128}
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, BgColor: color.RGBA{B: 255, A: 255}})
+	result, err := eng.RenderWithRegions(NewScene(NewPane([]Tileable{card}, 0, 0, 0)))
+	require.NoError(t, err)
+
+	region := result.Regions[card]
+	require.False(t, region.Empty())
+	// half of the red card over the blue canvas background
+	r, g, b, a := result.Canvas.Raw.At(region.Min.X+50, region.Min.Y+50).RGBA()
+	assert.InDelta(t, 128, r>>8, 2, "The card should blend its color by its alpha")
+	assert.Zero(t, g>>8)
+	assert.InDelta(t, 127, b>>8, 2, "The background beneath should show through the card")
+	assert.Equal(t, uint32(0xffff), a)
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, result.Canvas.Raw.At(region.Max.X+2, region.Min.Y+50), "The card should only cover the pane")
+}
+
+func Test_PanePadding(t *testing.T) {
+	blank := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 100, 100)), "")
+	card := NewPane([]Tileable{blank}, 100, 0, 0)
+	card.Padding = 5
+	card.PadLeft = 30
+	card.PadTop = 10
+
+	ctx := gg.NewContext(1, 1)
+	bw, bh := blank.IntrinsicSize(ctx, 100, 0)
+	w, h := card.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, bw+30+5, w, "The width should include the left and right padding")
+	assert.Equal(t, bh+10+5, h, "The height should include the top and bottom padding")
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	result, err := eng.RenderWithRegions(NewScene(NewPane([]Tileable{card}, 0, 0, 0)))
+	require.NoError(t, err)
+	outer, inner := result.Regions[card], result.Regions[blank]
+	require.False(t, inner.Empty())
+	assert.Equal(t, image.Pt(30, 10), inner.Min.Sub(outer.Min), "The objects should be shifted by the left and top padding")
+	assert.Equal(t, image.Pt(5, 5), outer.Max.Sub(inner.Max), "The right and bottom should fall back to Padding")
+}
+
+// borderedTile is a square tile with a border stroked around the outside of its box.
+type borderedTile struct {
+	size   float64
+	border float64
+}
+
+func (b borderedTile) Draw(ctx *gg.Context, cw float64, ch float64) {
+	ctx.SetLineWidth(b.border)
+	ctx.DrawRectangle(-b.border/2, -b.border/2, b.size+b.border, b.size+b.border)
+	ctx.Stroke()
+}
+
+func (b borderedTile) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return b.size, b.size
+}
+
+func (b borderedTile) Bleed() float64 {
+	return b.border
+}
+
+func Test_PanePadFloor(t *testing.T) {
+	ctx := gg.NewContext(1, 1)
+	tiles := []Tileable{borderedTile{size: 50, border: 6}, borderedTile{size: 50, border: 6}}
+
+	stack := NewPane(tiles, 50, 2, 2)
+	stack.Layout = LayoutStack
+	_, h := stack.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 50+12+50.0, h, "The row padding should be raised to clear the borders of both tiles")
+
+	row := NewPane(tiles, 50, 2, 2)
+	row.Layout = LayoutRow
+	w, _ := row.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 50+12+50.0, w, "The column padding should be raised to clear the borders of both tiles")
+
+	wide := NewPane(tiles, 50, 40, 40)
+	wide.Layout = LayoutStack
+	_, h = wide.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 50+40+50.0, h, "Paddings above the floor should be kept")
+
+	// the drawn objects are spaced by the raised padding too
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	outlined := TextBlockOpts{OutlineColor: color.White, OutlineWidth: 8}
+	first, second := NewTextBlock("First", outlined), NewTextBlock("Second", outlined)
+	text := NewPane([]Tileable{first, second}, 200, 2, 2)
+	text.Layout = LayoutStack
+	result, err := eng.RenderWithRegions(NewScene(text))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Regions[second].Min.Y-result.Regions[first].Max.Y, 16-1, "Outlined lines should not overlap")
+
+	shadowed := NewTextBlock("Up", TextBlockOpts{ShadowColor: color.Black, ShadowOffset: -3})
+	assert.Equal(t, 3.0, shadowed.Bleed(), "Shadows cast up or left should bleed by the size of their offset")
+	unlabeled := &ImageBlock{Image: image.NewRGBA(image.Rect(0, 0, 10, 10))}
+	assert.Zero(t, unlabeled.Bleed(), "Blocks without a label should not bleed")
+}
+
+// countingTile is a tileable of a fixed size that counts how often it is measured.
+type countingTile struct {
+	measures *atomic.Int32
+}
+
+func (c countingTile) Draw(ctx *gg.Context, cw float64, ch float64) {}
+
+func (c countingTile) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	c.measures.Add(1)
+	return 10, 10
+}
+
+func Test_MaxObjects(t *testing.T) {
+	var measures atomic.Int32
+	newObjects := func(n int) []Tileable {
+		objects := make([]Tileable, n)
+		for i := range objects {
+			objects[i] = countingTile{measures: &measures}
+		}
+		return objects
+	}
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, MaxObjects: 10})
+
+	// 6 objects next to a nested pane of 5 count as 12 objects
+	scene := NewScene(NewPane(append(newObjects(6), NewPane(newObjects(5), 0, 0, 0)), 0, 0, 0))
+	_, err := eng.Render(scene)
+	assert.ErrorIs(t, err, ErrTooManyObjects)
+	assert.ErrorContains(t, err, "12 objects")
+	assert.Zero(t, measures.Load(), "The scene should be rejected before it is laid out")
+
+	// panes wrapped however deeply count their objects too
+	wrapped := &Margined{Object: &TileProxy{Object: &Margined{Object: NewPane(newObjects(10), 0, 0, 0)}}}
+	_, err = eng.Render(NewScene(NewPane([]Tileable{wrapped}, 0, 0, 0)))
+	assert.ErrorContains(t, err, "11 objects")
+	assert.Equal(t, 16, countNested(&paneRow{panes: []*Pane{NewPane(newObjects(5), 0, 0, 0), NewPane([]Tileable{wrapped}, 0, 0, 0)}}))
+
+	_, err = eng.Render(NewScene(NewPane(newObjects(10), 0, 0, 0)))
+	assert.NoError(t, err, "Scenes within the limit should render")
+
+	_, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene)
+	assert.NoError(t, err, "Zero should mean unlimited")
+}
+
+func Test_FixedSize(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	solid := func(w int, h int) *ImageBlock {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+		return NewImageBlockFromImage(img, "")
+	}
+	eng := New(Config{FixedSize: &Size{Width: 1200, Height: 630}})
+
+	for name, block := range map[string]*ImageBlock{
+		"small":     solid(100, 100),
+		"wide":      solid(3000, 200),
+		"tall":      solid(200, 3000),
+		"too large": solid(4000, 4000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			pane := NewPane([]Tileable{block}, 8192, 0, 0)
+			pane.ShrinkColWidth = true
+			c, err := eng.Render(NewScene(pane))
+			require.NoError(t, err)
+			assert.Equal(t, 1200, c.Width, "Output width should always be the fixed width")
+			assert.Equal(t, 630, c.Height, "Output height should always be the fixed height")
+			assert.Equal(t, red, c.Raw.At(600, 315), "The content should be centered on the canvas")
+		})
+	}
+
+	c, err := eng.Render(NewScene(NewPane([]Tileable{solid(100, 100)}, 0, 0, 0)))
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, c.Raw.At(5, 315), "The letterbox should be filled with the background color")
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, c.Raw.At(600, 5), "The letterbox should be filled with the background color")
+
+	_, err = New(Config{FixedSize: &Size{}}).Render(NewScene(NewPane([]Tileable{solid(10, 10)}, 0, 0, 0)))
+	assert.Error(t, err, "Empty fixed sizes should be rejected")
+}
+
+func Test_ColumnPadBefore(t *testing.T) {
+	tile := func() Tileable { return NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 100, 50)), "") }
+	newShape := func(padBefore float64) *Shape {
+		return NewShapeWithObjects([]Column{
+			{Objects: []Tileable{tile()}},
+			{Objects: []Tileable{tile()}, PadBefore: padBefore},
+			{Objects: []Tileable{tile()}},
+		})
+	}
+	ctx := gg.NewContext(1, 1)
+
+	// xOffsets returns the left edge of the tile in each column as drawn
+	xOffsets := func(shape *Shape) []int {
+		pane := NewPaneWithShape(shape, 100, 10, 0)
+		state := &renderState{regions: make(map[Tileable]image.Rectangle)}
+		renderStates.Store(ctx, state)
+		defer renderStates.Delete(ctx)
+		pane.Draw(ctx, 0, 0)
+		var xs []int
+		for _, column := range shape.Columns {
+			xs = append(xs, state.regions[column.Objects[0]].Min.X)
+		}
+		return xs
+	}
+
+	assert.Equal(t, []int{0, 110, 220}, xOffsets(newShape(0)), "Columns should default to the pane column padding")
+	assert.Equal(t, []int{0, 140, 250}, xOffsets(newShape(40)), "A custom gap should shift the column and all after it")
+
+	w, _ := NewPaneWithShape(newShape(40), 100, 10, 0).IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 350.0, w, "The custom gap should be included in the pane width")
+}
+
+func Test_CaptionFontSize(t *testing.T) {
+	// heights returns the drawn heights of a single-line body text and a single-line image caption
+	heights := func(cfg Config) (float64, float64) {
+		body := NewTextBlock("Body", TextBlockOpts{TextWrap: true})
+		block := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 300, 100)), "Caption")
+		result, err := New(cfg).RenderWithRegions(NewScene(NewPane([]Tileable{body, block}, 0, 0, 0)))
+		require.NoError(t, err)
+		caption := result.Regions[block].Dy() - 100 - int(DefaultLabelPad)
+		return float64(result.Regions[body].Dy()), float64(caption)
+	}
+
+	body, caption := heights(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: 28})
+	assert.InDelta(t, body, caption, 1, "Captions should follow the engine font size by default")
+
+	body, caption = heights(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: 28, CaptionFontSize: 14})
+	assert.InDelta(t, body/2, caption, 2, "Captions should use the caption font size")
+	assert.Less(t, caption, body, "Captions should render smaller than body text")
+}
+
+func Test_NewPaneWithCols(t *testing.T) {
+	ctx := gg.NewContext(1, 1)
+	newObjects := func() []Tileable {
+		objects := make([]Tileable, 6)
+		for i := range objects {
+			objects[i] = NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 100, 60-i*10)), "")
+		}
+		return objects
+	}
+
+	pane := NewPaneWithCols(newObjects(), 3, 100, 0, 0)
+	shape, _ := pane.Shape(ctx)
+	require.Len(t, shape.Columns, 3, "The pane should have exactly the given column count")
+	heights := make([]float64, len(shape.Columns))
+	for i, column := range shape.Columns {
+		assert.Len(t, column.Objects, 2, "The objects should be balanced across the columns")
+		heights[i] = column.Height(ctx, 100, pane.RowPad)
+	}
+	assert.InDelta(t, slices.Min(heights), slices.Max(heights), 20, "The column heights should be balanced")
+
+	shape, _ = NewPaneWithCols(newObjects(), 1, 100, 0, 0).Shape(ctx)
+	assert.Len(t, shape.Columns, 1)
+	shape, _ = NewPaneWithCols(newObjects(), 10, 100, 0, 0).Shape(ctx)
+	assert.Len(t, shape.Columns, 6, "The column count should be capped at the number of objects")
+
+	for _, empty := range []*Pane{NewPaneWithCols(nil, 3, 100, 0, 0), NewPane(nil, 100, 0, 0)} {
+		shape, size := empty.Shape(ctx)
+		assert.Empty(t, shape.Columns, "An empty pane should have no columns")
+		assert.Zero(t, size)
+		_, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(empty))
+		assert.NoError(t, err, "An empty pane should render")
+	}
+}
+
+func Test_ImageBlockLabelOverlay(t *testing.T) {
+	gray := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(gray), image.Point{}, draw.Src)
+	block := NewImageBlockFromImage(img, "Magazine caption")
+	block.Opts.LabelOverlay = true
+
+	ctx := gg.NewContext(300, 200)
+	w, h := block.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 300.0, w)
+	assert.Equal(t, 200.0, h, "The overlaid label should not add to the image height")
+	_, scaledH := block.IntrinsicSize(ctx, 150, 0)
+	assert.Equal(t, 100.0, scaledH, "The height should follow the scaled image")
+
+	ctx.SetColor(color.Black)
+	block.Draw(ctx, w, h)
+	out := ctx.Image()
+	assert.Equal(t, gray, out.At(150, 10), "The top of the image should be left uncovered")
+	r, _, _, _ := out.At(299, 199).RGBA()
+	assert.Less(t, r>>8, uint32(100), "The bottom of the image should be darkened by the scrim")
+	brightest := uint32(0)
+	for y := 150; y < 200; y++ {
+		for x := range 300 {
+			r, _, _, _ := out.At(x, y).RGBA()
+			brightest = max(brightest, r>>8)
+		}
+	}
+	assert.Greater(t, brightest, uint32(220), "The caption should be drawn in white over the scrim")
+}
+
+func Test_EncodeUnderSize(t *testing.T) {
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(NewPane([]Tileable{
+		loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+	}, 0, 0, 0)))
+	require.NoError(t, err)
+	sizeAt := func(quality int) int {
+		var buf bytes.Buffer
+		require.NoError(t, c.ToJpeg(&buf, &jpeg.Options{Quality: quality}))
+		return buf.Len()
+	}
+
+	budget := sizeAt(60)
+	encoded, err := c.EncodeUnderSize(FormatJPEG, budget)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(encoded), budget)
+	assert.Greater(t, len(encoded), sizeAt(40), "The best quality under the budget should be picked")
+	decoded, err := jpeg.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+	assert.Equal(t, c.Width, decoded.Bounds().Dx())
+
+	_, err = c.EncodeUnderSize(FormatJPEG, sizeAt(1)-1)
+	assert.ErrorIs(t, err, ErrOverSizeBudget)
+
+	encoded, err = c.EncodeUnderSize(FormatPNG, 1<<30)
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(encoded))
+	assert.NoError(t, err)
+	_, err = c.EncodeUnderSize(FormatPNG, 100)
+	assert.ErrorIs(t, err, ErrOverSizeBudget)
+}
+
+func Test_AlignBaselines(t *testing.T) {
+	// baselineY returns the row below the lowest ink within the given object bounds, the baseline of text without descenders
+	baselineY := func(c *Canvas, bounds image.Rectangle) int {
+		for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if r, _, _, _ := c.Raw.At(x, y).RGBA(); r < 0x8000 {
+					return y + 1
+				}
+			}
+		}
+		return -1
+	}
+	render := func(align bool) *Canvas {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		pane := NewPane([]Tileable{
+			NewTextBlock("HELLO", TextBlockOpts{FontSize: 12}),
+			NewTextBlock("HUGE", TextBlockOpts{FontSize: 40}),
+			NewMargined(NewTextBlock("MID", TextBlockOpts{FontSize: 20}), Margin{Top: 5}),
+			NewImageBlockFromImage(img, ""),
+		}, 0, 0, 0)
+		pane.Layout = LayoutRow
+		pane.AlignBaselines = align
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(pane))
+		require.NoError(t, err)
+		return c
+	}
+
+	c := render(true)
+	bounds := c.Layout.Bounds
+	require.Len(t, bounds, 4)
+	small, large, mid := baselineY(c, bounds[0][0]), baselineY(c, bounds[1][0]), baselineY(c, bounds[2][0])
+	assert.InDelta(t, large, small, 1, "Texts of different sizes should share a baseline")
+	assert.InDelta(t, large, mid, 1, "Margins should be accounted for in the baseline")
+	assert.Greater(t, bounds[0][0].Min.Y, bounds[1][0].Min.Y, "The smaller text should be moved down")
+	assert.Equal(t, bounds[1][0].Min.Y, bounds[3][0].Min.Y, "Objects without a baseline should stay at the top")
+	assert.GreaterOrEqual(t, c.Height, bounds[0][0].Max.Y+int(DefaultOuterPad), "The pane should grow to fit the moved objects")
+
+	c = render(false)
+	bounds = c.Layout.Bounds
+	assert.Equal(t, bounds[0][0].Min.Y, bounds[1][0].Min.Y, "Objects should be top aligned by default")
+	assert.Greater(t, baselineY(c, bounds[1][0])-baselineY(c, bounds[0][0]), 10)
+
+	badge := NewBadge("Tag", nil, nil, 0)
+	ctx := gg.NewContext(1, 1)
+	assert.Equal(t, badge.PadY+ctx.FontHeight(), badge.Baseline(ctx, 0))
+	rotated := NewMargined(NewTextBlock("Side", TextBlockOpts{Rotation: 90}), Margin{Top: 5})
+	_, ok := baselineOf(ctx, rotated, 100)
+	assert.False(t, ok, "Rotated text should not be aligned by its baseline")
+}
+
+func Test_JpegMatte(t *testing.T) {
+	scene := NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+	// decodeCorner encodes the canvas to JPEG and returns the decoded color of its transparent top-left corner
+	decodeCorner := func(cfg Config, subsampling ChromaSubsampling) color.RGBA {
+		c, err := New(cfg).Render(scene)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, c.ToJpegWithOptions(&buf, &JpegOptions{Quality: 90, Subsampling: subsampling}))
+		img, err := jpeg.Decode(&buf)
+		require.NoError(t, err)
+		r, g, b, _ := img.At(1, 1).RGBA()
+		return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+	}
+
+	for _, subsampling := range []ChromaSubsampling{Subsampling420, Subsampling444} {
+		white := decodeCorner(Config{BgColor: color.Transparent}, subsampling)
+		assert.InDelta(t, 255, white.R, 2, "Transparent pixels should be white by default")
+		assert.InDelta(t, 255, white.G, 2)
+		assert.InDelta(t, 255, white.B, 2)
+
+		red := decodeCorner(Config{BgColor: color.Transparent, JpegMatte: color.RGBA{R: 255, A: 255}}, subsampling)
+		assert.InDelta(t, 255, red.R, 4, "Transparent pixels should take the configured matte color")
+		assert.InDelta(t, 0, red.G, 4)
+		assert.InDelta(t, 0, red.B, 4)
+	}
+
+	black := decodeCorner(Config{BgColor: color.Black}, Subsampling420)
+	assert.InDelta(t, 0, black.R, 2, "Opaque backgrounds should not be affected by the matte")
+}
+
+func Test_ImageBlockRightToLeftLabel(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 300, 40))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	face, err := New(Config{}).fontFace()
+	require.NoError(t, err)
+
+	// captionInk returns the leftmost and rightmost columns of the caption drawn under the image
+	captionInk := func(block *ImageBlock) (int, int) {
+		ctx := gg.NewContext(300, 100)
+		ctx.SetFontFace(face)
+		ctx.SetColor(color.White)
+		ctx.Clear()
+		ctx.SetColor(color.Black)
+		block.Draw(ctx, 300, 100)
+		minX, maxX := -1, -1
+		for x := range 300 {
+			for y := 40; y < 100; y++ {
+				if r, _, _, _ := ctx.Image().At(x, y).RGBA(); r < 0x8000 {
+					if minX < 0 {
+						minX = x
+					}
+					maxX = x
+					break
+				}
+			}
+		}
+		return minX, maxX
+	}
+
+	minX, _ := captionInk(NewImageBlockFromImage(white, "Photo"))
+	assert.Less(t, minX, 5, "Left-to-right captions should start at the left edge")
+	minX, maxX := captionInk(NewImageBlockFromImage(white, "صورة الغلاف"))
+	require.GreaterOrEqual(t, minX, 0, "The caption should be drawn")
+	assert.Greater(t, minX, 150, "Right-to-left captions should right-align under the image")
+	assert.Greater(t, maxX, 290)
+
+	rtl := NewImageBlockFromImage(white, "صورة الغلاف")
+	rtl.Label.Opts.HAlign = HAlignLeft
+	minX, _ = captionInk(rtl)
+	assert.Less(t, minX, 5, "An explicit alignment should override the text direction")
+}
+
+func Test_PaneVAlign(t *testing.T) {
+	tall := image.NewRGBA(image.Rect(0, 0, 100, 200))
+	draw.Draw(tall, tall.Bounds(), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	// textRows returns the first and last rows with dark ink within the region of the label
+	textRows := func(c *Canvas, region image.Rectangle) (int, int) {
+		first, last := -1, -1
+		for y := region.Min.Y; y < region.Max.Y; y++ {
+			for x := region.Min.X; x < region.Max.X; x++ {
+				if r, g, b, _ := c.Raw.At(x, y).RGBA(); r < 0x8000 && g < 0x8000 && b < 0x8000 {
+					if first < 0 {
+						first = y
+					}
+					last = y
+					break
+				}
+			}
+		}
+		return first, last
+	}
+
+	for _, layout := range []string{"Row", "Grid"} {
+		t.Run(layout, func(t *testing.T) {
+			photo := NewImageBlockFromImage(tall, "")
+			label := NewTextBlock("Label", TextBlockOpts{VAlign: VAlignMiddle})
+			pane := NewPane([]Tileable{photo, label}, 200, 0, 0)
+			if layout == "Row" {
+				pane.Layout = LayoutRow
+			} else {
+				pane.GridRows = 1
+			}
+			result, err := eng.RenderWithRegions(NewScene(pane))
+			require.NoError(t, err)
+			imageRegion, labelRegion := result.Regions[photo], result.Regions[label]
+			assert.Equal(t, imageRegion.Dy(), labelRegion.Dy(), "The label should be drawn in the full height of the row")
+			first, last := textRows(result.Canvas, labelRegion)
+			require.GreaterOrEqual(t, first, 0)
+			center := (imageRegion.Min.Y + imageRegion.Max.Y) / 2
+			assert.InDelta(t, center, (first+last)/2, 6, "The short label should be centered beside the image")
+		})
+	}
+}
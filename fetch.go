@@ -0,0 +1,44 @@
+package imacon
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultFetchTimeout = 30 * time.Second // The default timeout for fetching an image when the context has no deadline
+
+// NewImageBlockFromURL fetches an image over HTTP and decodes it into an image block with the given label.
+// The request is bound to ctx, and to DefaultFetchTimeout when ctx has no deadline of its own.
+func NewImageBlockFromURL(ctx context.Context, url string, label string) (*ImageBlock, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultFetchTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("imacon: invalid image URL %q: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("imacon: failed to fetch image %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("imacon: failed to fetch image %q: unexpected status %s", url, resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !strings.HasPrefix(mediaType, "image/") {
+			return nil, fmt.Errorf("imacon: failed to fetch image %q: unsupported content type %q", url, contentType)
+		}
+	}
+
+	return NewImageBlock(resp.Body, label)
+}
@@ -0,0 +1,52 @@
+package imacon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewImageBlockFromURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sample.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "assets/samples/sample_1.jpg")
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/slow.jpg", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("Fetch sample image", func(t *testing.T) {
+		img, err := NewImageBlockFromURL(context.Background(), server.URL+"/sample.jpg", "Face")
+		require.NoError(t, err)
+		assert.Equal(t, 485, img.Image.Bounds().Dx())
+		assert.Equal(t, "Face", img.Label.Text)
+	})
+
+	t.Run("Non-200 response", func(t *testing.T) {
+		_, err := NewImageBlockFromURL(context.Background(), server.URL+"/missing.jpg", "Missing")
+		assert.ErrorContains(t, err, "404")
+	})
+
+	t.Run("Unsupported content type", func(t *testing.T) {
+		_, err := NewImageBlockFromURL(context.Background(), server.URL+"/page.html", "Page")
+		assert.ErrorContains(t, err, "unsupported content type")
+	})
+
+	t.Run("Context cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := NewImageBlockFromURL(ctx, server.URL+"/slow.jpg", "Slow")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
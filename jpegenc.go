@@ -0,0 +1,410 @@
+// This file forks the baseline encoder of the image/jpeg package for Subsampling444. The jpeg package always writes
+// 4:2:0, and unlike the APP0 and density segments that metadata.go splices into its output, the sampling factors are
+// baked into the frame header and every scan, so they cannot be changed after encoding. The fork is kept to the one
+// mode the jpeg package lacks: Subsampling420, the default, still encodes with the jpeg package. The fork also trades
+// the jpeg package's fixed-point DCT for a plain separable float one, which is slower but can be checked against the
+// spec at a glance.
+//
+// The tables and the entropy coding of this file are adapted from the image/jpeg package of the Go standard library:
+//
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imacon
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// ChromaSubsampling is the resolution at which a JPEG stores the color of the image, relative to its brightness.
+type ChromaSubsampling int
+
+const (
+	Subsampling420 ChromaSubsampling = iota // Color at half the resolution in both directions, as written by the jpeg package
+	Subsampling444                          // Color at full resolution, keeping the edges of colored text and lines sharp
+)
+
+// JpegOptions are the encoding parameters of Canvas.ToJpegWithOptions.
+type JpegOptions struct {
+	Quality     int               // Ranges from 1 to 100 inclusive, higher is better. Zero uses the engine's DefaultJpegQuality.
+	Subsampling ChromaSubsampling // Defaults to Subsampling420
+}
+
+// jpegUnscaledQuant are the quantization tables of section K.1 of the spec in zig-zag order, for luminance then chrominance.
+var jpegUnscaledQuant = [2][64]byte{
+	// Luminance.
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	// Chrominance.
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegUnzig maps the zig-zag index of a coefficient to its natural index.
+var jpegUnzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegHuffmanSpec specifies a Huffman encoding: count[i] is the number of codes of length i+1 bits, and value[i] the
+// decoded value of the i'th codeword.
+type jpegHuffmanSpec struct {
+	count [16]byte
+	value []byte
+}
+
+// jpegHuffmanSpecs are the Huffman encodings of section K.3 of the spec, for luminance DC and AC then chrominance DC and AC.
+var jpegHuffmanSpecs = [4]jpegHuffmanSpec{
+	// Luminance DC.
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// Luminance AC.
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	// Chrominance DC.
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// Chrominance AC.
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegHuffmanLUTs are jpegHuffmanSpecs compiled into look-up tables, mapping each value to its codeword size in bits, in
+// the 8 most significant bits, and its codeword, in the 24 least significant bits.
+var jpegHuffmanLUTs [4][]uint32
+
+// jpegCos holds cos((2x+1)uπ/16) at [x][u] for the forward DCT.
+var jpegCos [8][8]float64
+
+func init() {
+	for i, s := range jpegHuffmanSpecs {
+		lut := make([]uint32, int(slices.Max(s.value))+1)
+		code, k := uint32(0), 0
+		for n := range s.count {
+			for j := byte(0); j < s.count[n]; j++ {
+				lut[s.value[k]] = uint32(n+1)<<24 | code
+				code++
+				k++
+			}
+			code <<= 1
+		}
+		jpegHuffmanLUTs[i] = lut
+	}
+	for x := range 8 {
+		for u := range 8 {
+			jpegCos[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+		}
+	}
+}
+
+// jpegEncoder writes baseline JPEG with every component sampled at full resolution, which the jpeg package cannot do.
+// The first write error is kept, turning the following writes into no-ops.
+type jpegEncoder struct {
+	w           *bufio.Writer
+	err         error
+	bits, nBits uint32      // The bits accumulated for the bit-stream
+	quant       [2][64]byte // The quantization tables scaled for the quality, in zig-zag order
+}
+
+// encodeJpeg444 writes the image to the writer as a baseline JPEG with 4:4:4 chroma subsampling.
+// The quality is clipped to [1, 100], with zero meaning jpeg.DefaultQuality.
+func encodeJpeg444(writer io.Writer, img image.Image, quality int) error {
+	size := img.Bounds().Size()
+	if size.X >= 1<<16 || size.Y >= 1<<16 {
+		return errors.New("imacon: image is too large to encode as JPEG")
+	}
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	quality = min(max(quality, 1), 100)
+
+	// the quality rating converts to a scaling factor of the tables like in the jpeg package
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	e := &jpegEncoder{w: bufio.NewWriter(writer)}
+	for i := range e.quant {
+		for j, q := range jpegUnscaledQuant[i] {
+			e.quant[i][j] = uint8(min(max((int(q)*scale+50)/100, 1), 255))
+		}
+	}
+
+	e.write([]byte{0xff, 0xd8}) // Start Of Image
+	e.writeDQT()
+	e.writeSOF0(size)
+	e.writeDHT()
+	e.writeSOS(img)
+	e.write([]byte{0xff, 0xd9}) // End Of Image
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+func (e *jpegEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+}
+
+func (e *jpegEncoder) writeByte(b byte) {
+	if e.err != nil {
+		return
+	}
+	e.err = e.w.WriteByte(b)
+}
+
+func (e *jpegEncoder) writeMarkerHeader(marker byte, length int) {
+	e.write([]byte{0xff, marker, byte(length >> 8), byte(length)})
+}
+
+// emit emits the least significant nBits bits of bits to the bit-stream, stuffing a zero byte after every 0xff.
+// The precondition is bits < 1<<nBits && nBits <= 16.
+func (e *jpegEncoder) emit(bits, nBits uint32) {
+	nBits += e.nBits
+	bits <<= 32 - nBits
+	bits |= e.bits
+	for nBits >= 8 {
+		b := uint8(bits >> 24)
+		e.writeByte(b)
+		if b == 0xff {
+			e.writeByte(0x00)
+		}
+		bits <<= 8
+		nBits -= 8
+	}
+	e.bits, e.nBits = bits, nBits
+}
+
+// emitHuff emits the value with the Huffman encoding of the given index into jpegHuffmanSpecs.
+func (e *jpegEncoder) emitHuff(h int, value int32) {
+	x := jpegHuffmanLUTs[h][value]
+	e.emit(x&(1<<24-1), x>>24)
+}
+
+// emitHuffRLE emits a value preceded by a run of runLength zeros.
+func (e *jpegEncoder) emitHuffRLE(h int, runLength int32, value int32) {
+	a, b := value, value
+	if a < 0 {
+		a, b = -value, value-1
+	}
+	nBits := uint32(bits.Len32(uint32(a)))
+	e.emitHuff(h, runLength<<4|int32(nBits))
+	if nBits > 0 {
+		e.emit(uint32(b)&(1<<nBits-1), nBits)
+	}
+}
+
+// writeDQT writes the Define Quantization Table marker.
+func (e *jpegEncoder) writeDQT() {
+	e.writeMarkerHeader(0xdb, 2+len(e.quant)*(1+64))
+	for i := range e.quant {
+		e.writeByte(byte(i))
+		e.write(e.quant[i][:])
+	}
+}
+
+// writeSOF0 writes the Start Of Frame (Baseline Sequential) marker, with Y, Cb and Cr all sampled 1x1.
+func (e *jpegEncoder) writeSOF0(size image.Point) {
+	e.writeMarkerHeader(0xc0, 8+3*3)
+	e.write([]byte{8, byte(size.Y >> 8), byte(size.Y), byte(size.X >> 8), byte(size.X), 3})
+	for i := range 3 {
+		e.write([]byte{byte(i + 1), 0x11, "\x00\x01\x01"[i]})
+	}
+}
+
+// writeDHT writes the Define Huffman Table marker.
+func (e *jpegEncoder) writeDHT() {
+	length := 2
+	for _, s := range jpegHuffmanSpecs {
+		length += 1 + 16 + len(s.value)
+	}
+	e.writeMarkerHeader(0xc4, length)
+	for i, s := range jpegHuffmanSpecs {
+		e.writeByte("\x00\x10\x01\x11"[i])
+		e.write(s.count[:])
+		e.write(s.value)
+	}
+}
+
+// writeSOS writes the Start Of Scan marker and the image data, one 8x8 block of each component per MCU.
+func (e *jpegEncoder) writeSOS(img image.Image) {
+	// Y uses the luminance tables, Cb and Cr the chrominance ones, over the full spectral range
+	e.write([]byte{0xff, 0xda, 0x00, 0x0c, 0x03, 0x01, 0x00, 0x02, 0x11, 0x03, 0x11, 0x00, 0x3f, 0x00})
+	var (
+		y, cb, cr                   [64]float64
+		prevDCY, prevDCCb, prevDCCr int32
+	)
+	bounds := img.Bounds()
+	rgba, _ := img.(*image.RGBA)
+	for by := bounds.Min.Y; by < bounds.Max.Y; by += 8 {
+		for bx := bounds.Min.X; bx < bounds.Max.X; bx += 8 {
+			for j := range 8 {
+				for i := range 8 {
+					// the edge pixels are repeated to fill the blocks overhanging the image
+					px, py := min(bx+i, bounds.Max.X-1), min(by+j, bounds.Max.Y-1)
+					var r, g, b uint8
+					if rgba != nil {
+						pix := rgba.Pix[rgba.PixOffset(px, py):]
+						r, g, b = pix[0], pix[1], pix[2]
+					} else {
+						r32, g32, b32, _ := img.At(px, py).RGBA()
+						r, g, b = uint8(r32>>8), uint8(g32>>8), uint8(b32>>8)
+					}
+					yy, u, v := color.RGBToYCbCr(r, g, b)
+					y[8*j+i], cb[8*j+i], cr[8*j+i] = float64(yy), float64(u), float64(v)
+				}
+			}
+			prevDCY = e.writeBlock(&y, 0, prevDCY)
+			prevDCCb = e.writeBlock(&cb, 1, prevDCCb)
+			prevDCCr = e.writeBlock(&cr, 1, prevDCCr)
+		}
+	}
+	// pad the last byte with 1's
+	e.emit(0x7f, 7)
+}
+
+// writeBlock transforms and quantizes a block of samples in natural order with the given quantization table, and
+// writes it delta-encoded against prevDC, returning its quantized DC value.
+func (e *jpegEncoder) writeBlock(samples *[64]float64, q int, prevDC int32) int32 {
+	coeffs := jpegFDCT(samples)
+	dc := int32(math.Round(coeffs[0] / float64(e.quant[q][0])))
+	e.emitHuffRLE(2*q, 0, dc-prevDC)
+
+	h, runLength := 2*q+1, int32(0)
+	for zig := 1; zig < 64; zig++ {
+		ac := int32(math.Round(coeffs[jpegUnzig[zig]] / float64(e.quant[q][zig])))
+		if ac == 0 {
+			runLength++
+			continue
+		}
+		for runLength > 15 {
+			e.emitHuff(h, 0xf0) // ZRL, a run of 16 zeros
+			runLength -= 16
+		}
+		e.emitHuffRLE(h, runLength, ac)
+		runLength = 0
+	}
+	if runLength > 0 {
+		e.emitHuff(h, 0x00) // EOB
+	}
+	return dc
+}
+
+// jpegFDCT returns the 2D forward DCT of the level-shifted samples, separated into 1D transforms of the rows then the columns.
+func jpegFDCT(samples *[64]float64) [64]float64 {
+	var rows, coeffs [64]float64
+	for y := range 8 {
+		for u := range 8 {
+			var sum float64
+			for x := range 8 {
+				sum += (samples[8*y+x] - 128) * jpegCos[x][u]
+			}
+			rows[8*y+u] = sum
+		}
+	}
+	for u := range 8 {
+		for v := range 8 {
+			var sum float64
+			for y := range 8 {
+				sum += rows[8*y+u] * jpegCos[y][v]
+			}
+			coeffs[8*v+u] = sum * jpegDCTScale(u) * jpegDCTScale(v) / 4
+		}
+	}
+	return coeffs
+}
+
+// jpegDCTScale returns the normalization factor of the DCT coefficient of frequency k, which is 1/√2 for the DC term
+// so that the transform stays orthonormal.
+func jpegDCTScale(k int) float64 {
+	if k == 0 {
+		return math.Sqrt2 / 2
+	}
+	return 1
+}
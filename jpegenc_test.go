@@ -0,0 +1,80 @@
+package imacon
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chromaError sums the distance of the chroma of the decoded image from the original, pixel by pixel.
+func chromaError(t *testing.T, original image.Image, encoded []byte) int {
+	decoded, err := jpeg.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+	require.Equal(t, original.Bounds().Size(), decoded.Bounds().Size())
+	total := 0
+	b := original.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r0, g0, b0, _ := original.At(x, y).RGBA()
+			_, cb0, cr0 := color.RGBToYCbCr(uint8(r0>>8), uint8(g0>>8), uint8(b0>>8))
+			r1, g1, b1, _ := decoded.At(x-b.Min.X, y-b.Min.Y).RGBA()
+			_, cb1, cr1 := color.RGBToYCbCr(uint8(r1>>8), uint8(g1>>8), uint8(b1>>8))
+			total += abs(int(cb0)-int(cb1)) + abs(int(cr0)-int(cr1))
+		}
+	}
+	return total
+}
+
+func Test_ToJpegWithOptions(t *testing.T) {
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(NewScene(NewPane([]Tileable{
+		NewTextBlock("Sharp red text on white", TextBlockOpts{FontSize: 14, Color: color.RGBA{R: 220, A: 255}}),
+	}, 0, 0, 0)))
+	require.NoError(t, err)
+
+	var subsampled, full, standard bytes.Buffer
+	require.NoError(t, c.ToJpegWithOptions(&subsampled, &JpegOptions{Quality: 95}))
+	require.NoError(t, c.ToJpegWithOptions(&full, &JpegOptions{Quality: 95, Subsampling: Subsampling444}))
+	require.NoError(t, c.ToJpeg(&standard, &jpeg.Options{Quality: 95}))
+	assert.Equal(t, standard.Bytes(), subsampled.Bytes(), "4:2:0 should be the standard behavior of the jpeg package")
+	assert.NotEqual(t, subsampled.Bytes(), full.Bytes())
+
+	subsampledErr, fullErr := chromaError(t, c.Raw, subsampled.Bytes()), chromaError(t, c.Raw, full.Bytes())
+	assert.Less(t, fullErr, subsampledErr/2, "4:4:4 should keep the color of the text edges much closer to the original")
+
+	var fallback, defaults bytes.Buffer
+	require.NoError(t, c.ToJpegWithOptions(&fallback, nil))
+	require.NoError(t, c.ToJpeg(&defaults, nil))
+	assert.Equal(t, defaults.Bytes(), fallback.Bytes(), "No options should encode like ToJpeg")
+	assert.Error(t, c.ToJpegWithOptions(&fallback, &JpegOptions{Subsampling: ChromaSubsampling(7)}))
+}
+
+func Test_EncodeJpeg444(t *testing.T) {
+	// an odd size exercises the blocks overhanging the image, and a non-RGBA image the generic path
+	img := image.NewNRGBA(image.Rect(3, 5, 20, 18))
+	for y := 5; y < 18; y++ {
+		for x := 3; x < 20; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 12), G: uint8(y * 12), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encodeJpeg444(&buf, img, 100))
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 17, cfg.Width)
+	assert.Equal(t, 13, cfg.Height)
+
+	decoded, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	ycbcr, ok := decoded.(*image.YCbCr)
+	require.True(t, ok)
+	assert.Equal(t, image.YCbCrSubsampleRatio444, ycbcr.SubsampleRatio)
+	r, g, b, _ := decoded.At(10, 6).RGBA()
+	assert.InDelta(t, 13*12, int(r>>8), 6)
+	assert.InDelta(t, 11*12, int(g>>8), 6)
+	assert.InDelta(t, 128, int(b>>8), 6)
+}
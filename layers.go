@@ -0,0 +1,52 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/fogleman/gg"
+)
+
+// Layer is a single object of a scene rendered onto its own transparent image.
+type Layer struct {
+	Object   Tileable    // The object drawn on the layer
+	Image    *image.RGBA // The drawn object, cropped to its region on the canvas
+	Position image.Point // The position of the top-left corner of the image on the canvas
+}
+
+// RenderLayers renders each top-level object of the scene's main pane, or of each of its Panes, onto its own transparent
+// layer, in drawing order, so that downstream tools can recompose or animate them. Drawing the layers at their positions
+// over the background color reproduces the main pane of the flat render. The header, footer, overlays and canvas border are not included.
+func (e *Engine) RenderLayers(scene *Scene) ([]Layer, error) {
+	plan, err := e.plan(scene, e.newRenderState())
+	if err != nil {
+		return nil, err
+	}
+	layerPlan := *plan
+	layerPlan.bgColor = color.Transparent
+
+	// the planned shape is cached by the measurement, so the layers follow the same layout as Render
+	var objects []Tileable
+	for _, pane := range scene.mainPanes() {
+		for _, column := range unwrapShape(*pane.PlannedShape).Columns {
+			objects = append(objects, column.Objects...)
+		}
+	}
+
+	ctx := gg.NewContext(e.scaled(plan.width), e.scaled(plan.height))
+	canvas := image.Rect(0, 0, ctx.Width(), ctx.Height())
+	layers := make([]Layer, 0, len(objects))
+	for _, obj := range objects {
+		state := e.newRenderState()
+		state.regions = make(map[Tileable]image.Rectangle)
+		state.layer = obj
+		e.draw(ctx, scene, &layerPlan, 0, state)
+
+		region := state.regions[obj].Intersect(canvas)
+		img := image.NewRGBA(image.Rectangle{Max: region.Size()})
+		draw.Draw(img, img.Bounds(), ctx.Image(), region.Min, draw.Src)
+		layers = append(layers, Layer{Object: obj, Image: img, Position: region.Min})
+	}
+	return layers, nil
+}
@@ -0,0 +1,54 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenderLayers(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewTextBlock("Character Sheet", TextBlockOpts{FontSize: 24}),
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+			loadImageBlock(t, "assets/samples/glasses.png", "Glasses"),
+			NewDivider(2, nil),
+		}, 0, 0, 0))
+	}
+	eng := New(Config{MaxCanvasWidth: 1200, MaxCanvasHeight: 1200})
+
+	scene := newScene()
+	layers, err := eng.RenderLayers(scene)
+	require.NoError(t, err)
+	require.Len(t, layers, len(scene.Main.Objects), "There should be one layer per object in the main pane")
+	for _, layer := range layers {
+		assert.Contains(t, scene.Main.Objects, layer.Object)
+	}
+
+	flat, err := eng.Render(newScene())
+	require.NoError(t, err)
+
+	composite := image.NewRGBA(image.Rect(0, 0, flat.Width, flat.Height))
+	draw.Draw(composite, composite.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, layer := range layers {
+		draw.Draw(composite, layer.Image.Bounds().Add(layer.Position), layer.Image, image.Point{}, draw.Over)
+	}
+
+	flatRGBA := flat.Raw.(*image.RGBA)
+	maxDiff := 0
+	for i := range composite.Pix {
+		maxDiff = max(maxDiff, abs(int(composite.Pix[i])-int(flatRGBA.Pix[i])))
+	}
+	assert.LessOrEqual(t, maxDiff, 2, "Compositing the layers should reproduce the flat render")
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
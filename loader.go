@@ -0,0 +1,184 @@
+package imacon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// DrawableFactory constructs a tileable from the JSON object describing it, including its "type" field.
+type DrawableFactory func(data json.RawMessage) (Tileable, error)
+
+// loaderFactory constructs a tileable like DrawableFactory, opening the files it refers to from fsys.
+type loaderFactory func(data json.RawMessage, fsys fs.FS) (Tileable, error)
+
+var (
+	drawableFactoriesMu sync.RWMutex
+	drawableFactories   = make(map[string]loaderFactory)
+)
+
+// the built-in types are registered in init, as the pane factory refers back to the registry for its objects
+func init() {
+	registerLoader("text", func(data json.RawMessage, _ fs.FS) (Tileable, error) { return parseTextBlock(data) })
+	registerLoader("image", parseImageBlock)
+	registerLoader("pane", parsePaneObject)
+}
+
+// RegisterDrawable makes a custom tileable available to ParseScene under the given type name, e.g. for charts or QR codes.
+// It panics if the factory is nil or the type name is already registered, including the built-in "text", "image" and "pane".
+func RegisterDrawable(typeName string, factory DrawableFactory) {
+	if factory == nil {
+		panic("imacon: RegisterDrawable factory is nil")
+	}
+	registerLoader(typeName, func(data json.RawMessage, _ fs.FS) (Tileable, error) { return factory(data) })
+}
+
+// registerLoader adds a factory to the registry, panicking like RegisterDrawable when the type is already registered.
+func registerLoader(typeName string, factory loaderFactory) {
+	drawableFactoriesMu.Lock()
+	defer drawableFactoriesMu.Unlock()
+	if _, dup := drawableFactories[typeName]; dup {
+		panic("imacon: RegisterDrawable called twice for type " + typeName)
+	}
+	drawableFactories[typeName] = factory
+}
+
+// unregisterDrawable removes a type added with RegisterDrawable, so that tests can register their types again.
+func unregisterDrawable(typeName string) {
+	drawableFactoriesMu.Lock()
+	defer drawableFactoriesMu.Unlock()
+	delete(drawableFactories, typeName)
+}
+
+// sceneJSON is the JSON representation of a scene read by ParseScene.
+type sceneJSON struct {
+	Main        json.RawMessage `json:"main"`
+	Header      json.RawMessage `json:"header"`
+	Footer      json.RawMessage `json:"footer"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+}
+
+// paneJSON is the JSON representation of a pane, whose objects are tileables of any registered type.
+type paneJSON struct {
+	Objects  []json.RawMessage `json:"objects"`
+	ColWidth float64           `json:"colWidth"`
+	ColPad   float64           `json:"colPad"`
+	RowPad   float64           `json:"rowPad"`
+}
+
+// ParseScene builds a scene from its JSON description. The main, header and footer panes are objects of type "pane",
+// holding tileables of the types "text", "image", "pane" or any type added with RegisterDrawable:
+//
+//	{"title": "Outfit", "main": {"type": "pane", "colWidth": 360, "objects": [
+//		{"type": "text", "text": "Summer", "wrap": true},
+//		{"type": "image", "path": "assets/samples/sample_1.jpg", "label": "Face"}
+//	]}}
+//
+// Every pane must hold at least one object. The image paths are opened relative to the working directory, and absolute
+// paths or paths leading out of it with ".." are rejected. Use ParseSceneFS to confine them to another directory when the JSON comes from untrusted users.
+func ParseScene(data []byte) (*Scene, error) {
+	return ParseSceneFS(os.DirFS("."), data)
+}
+
+// ParseSceneFS builds a scene from its JSON description like ParseScene, opening the image paths from fsys, so that
+// scene JSON can only read the files within it.
+func ParseSceneFS(fsys fs.FS, data []byte) (*Scene, error) {
+	var doc sceneJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("imacon: failed to parse scene: %w", err)
+	}
+	if doc.Main == nil {
+		return nil, fmt.Errorf("imacon: scene has no Main pane")
+	}
+	scene := &Scene{Title: doc.Title, Description: doc.Description}
+	for _, band := range []struct {
+		data json.RawMessage
+		pane **Pane
+	}{{doc.Main, &scene.Main}, {doc.Header, &scene.Header}, {doc.Footer, &scene.Footer}} {
+		if band.data == nil {
+			continue
+		}
+		obj, err := parseTileable(band.data, fsys)
+		if err != nil {
+			return nil, err
+		}
+		pane, ok := obj.(*Pane)
+		if !ok {
+			return nil, fmt.Errorf("imacon: scene bands must be of type pane, got %T", obj)
+		}
+		*band.pane = pane
+	}
+	return scene, nil
+}
+
+// parseTileable constructs a tileable with the factory registered for its "type" field.
+func parseTileable(data json.RawMessage, fsys fs.FS) (Tileable, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("imacon: failed to parse object: %w", err)
+	}
+	drawableFactoriesMu.RLock()
+	factory, ok := drawableFactories[header.Type]
+	drawableFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("imacon: unknown object type %q", header.Type)
+	}
+	obj, err := factory(data, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("imacon: failed to load %s object: %w", header.Type, err)
+	}
+	return obj, nil
+}
+
+func parseTextBlock(data json.RawMessage) (Tileable, error) {
+	var text struct {
+		Text          string  `json:"text"`
+		Wrap          bool    `json:"wrap"`
+		FontSize      float64 `json:"fontSize"`
+		LetterSpacing float64 `json:"letterSpacing"`
+	}
+	if err := json.Unmarshal(data, &text); err != nil {
+		return nil, err
+	}
+	return NewTextBlock(text.Text, TextBlockOpts{TextWrap: text.Wrap, FontSize: text.FontSize, LetterSpacing: text.LetterSpacing}), nil
+}
+
+func parseImageBlock(data json.RawMessage, fsys fs.FS) (Tileable, error) {
+	var img struct {
+		Path  string `json:"path"`
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(data, &img); err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(img.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewImageBlock(file, img.Label)
+}
+
+func parsePaneObject(data json.RawMessage, fsys fs.FS) (Tileable, error) {
+	var pane paneJSON
+	if err := json.Unmarshal(data, &pane); err != nil {
+		return nil, err
+	}
+	if len(pane.Objects) == 0 {
+		return nil, fmt.Errorf("imacon: pane has no objects")
+	}
+	objects := make([]Tileable, len(pane.Objects))
+	for i, raw := range pane.Objects {
+		obj, err := parseTileable(raw, fsys)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = obj
+	}
+	return NewPane(objects, pane.ColWidth, pane.ColPad, pane.RowPad), nil
+}
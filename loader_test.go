@@ -0,0 +1,75 @@
+package imacon
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dummyTile is a custom tileable of a fixed square size, loaded from JSON through the registry.
+type dummyTile struct {
+	Size float64 `json:"size"`
+}
+
+func (d *dummyTile) Draw(ctx *gg.Context, cw float64, ch float64) {
+	fillRect(ctx, 0, 0, d.Size, d.Size)
+}
+
+func (d *dummyTile) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	return d.Size, d.Size
+}
+
+func Test_RegisterDrawable(t *testing.T) {
+	RegisterDrawable("dummy", func(data json.RawMessage) (Tileable, error) {
+		tile := &dummyTile{}
+		return tile, json.Unmarshal(data, tile)
+	})
+	t.Cleanup(func() { unregisterDrawable("dummy") })
+	assert.Panics(t, func() { RegisterDrawable("dummy", parseTextBlock) }, "Types should not be registered twice")
+	assert.Panics(t, func() { RegisterDrawable("text", parseTextBlock) }, "Built-in types should be registered")
+
+	scene, err := ParseScene([]byte(`{"title": "Custom", "main": {"type": "pane", "colWidth": 300, "objects": [
+		{"type": "dummy", "size": 64},
+		{"type": "text", "text": "Caption", "wrap": true},
+		{"type": "pane", "objects": [{"type": "image", "path": "assets/samples/glasses.png", "label": "Glasses"}]}
+	]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Custom", scene.Title)
+	require.Len(t, scene.Main.Objects, 3)
+	assert.Equal(t, &dummyTile{Size: 64}, scene.Main.Objects[0], "The custom type should be constructed by its factory")
+	assert.IsType(t, &TextBlock{}, scene.Main.Objects[1])
+	require.IsType(t, &Pane{}, scene.Main.Objects[2])
+	assert.IsType(t, &ImageBlock{}, scene.Main.Objects[2].(*Pane).Objects[0])
+	assert.Equal(t, 300.0, scene.Main.ColWidth)
+
+	_, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(scene)
+	assert.NoError(t, err, "Loaded scenes should render")
+
+	_, err = ParseScene([]byte(`{"main": {"type": "pane", "objects": [{"type": "unknown"}]}}`))
+	assert.ErrorContains(t, err, `unknown object type "unknown"`)
+	_, err = ParseScene([]byte(`{"main": {"type": "dummy", "size": 1}}`))
+	assert.Error(t, err, "Scene bands should be panes")
+	for _, empty := range []string{`{"main": {"type": "pane"}}`, `{"main": {"type": "pane", "objects": [{"type": "pane", "objects": []}]}}`} {
+		_, err = ParseScene([]byte(empty))
+		assert.ErrorContains(t, err, "pane has no objects", "Panes without objects should be rejected")
+	}
+}
+
+func Test_ParseSceneFS(t *testing.T) {
+	scene := []byte(`{"main": {"type": "pane", "objects": [{"type": "image", "path": "samples/glasses.png"}]}}`)
+	parsed, err := ParseSceneFS(os.DirFS("assets"), scene)
+	require.NoError(t, err)
+	assert.IsType(t, &ImageBlock{}, parsed.Main.Objects[0], "Paths should be opened from the given file system")
+
+	for _, path := range []string{"../go.mod", "/etc/passwd"} {
+		escaping := []byte(`{"main": {"type": "pane", "objects": [{"type": "image", "path": "` + path + `"}]}}`)
+		_, err = ParseSceneFS(os.DirFS("assets"), escaping)
+		assert.Error(t, err, "Path %q should not escape the file system", path)
+		_, err = ParseScene(escaping)
+		assert.Error(t, err, "Path %q should not escape the working directory", path)
+	}
+}
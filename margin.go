@@ -0,0 +1,51 @@
+package imacon
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Margin defines the extra space reserved around each side of an object.
+type Margin struct {
+	Top    float64
+	Right  float64
+	Bottom float64
+	Left   float64
+}
+
+// Margined wraps a tileable object and reserves a margin around it, independent of the pane's row and column padding.
+type Margined struct {
+	Object Tileable
+	Margin Margin
+}
+
+func NewMargined(object Tileable, margin Margin) *Margined {
+	return &Margined{Object: object, Margin: margin}
+}
+
+// inner returns the size available to the wrapped object within the given outer size.
+// Zero dimensions are kept as zero, meaning unconstrained.
+func (m *Margined) inner(w float64, h float64) (float64, float64) {
+	if w != 0 {
+		w = math.Max(w-m.Margin.Left-m.Margin.Right, 1)
+	}
+	if h != 0 {
+		h = math.Max(h-m.Margin.Top-m.Margin.Bottom, 1)
+	}
+	return w, h
+}
+
+func (m *Margined) Draw(ctx *gg.Context, cw float64, ch float64) {
+	w, h := m.inner(cw, ch)
+	ctx.Push()
+	ctx.Translate(m.Margin.Left, m.Margin.Top)
+	m.Object.Draw(ctx, w, h)
+	ctx.Pop()
+}
+
+func (m *Margined) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	innerW, innerH := m.inner(expectedWidth, expectedHeight)
+	w, h := m.Object.IntrinsicSize(ctx, innerW, innerH)
+	return w + m.Margin.Left + m.Margin.Right, h + m.Margin.Top + m.Margin.Bottom
+}
@@ -0,0 +1,31 @@
+package imacon
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Margined(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	margin := Margin{Top: 10, Right: 20, Bottom: 30, Left: 40}
+
+	t.Run("Margined text block reserves extra space", func(t *testing.T) {
+		text := NewTextBlock("Lorem ipsum dolor sit amet, consectetur adipiscing elit.", TextBlockOpts{TextWrap: true})
+		w, h := text.IntrinsicSize(ctx, 300-60, 0)
+		mw, mh := NewMargined(text, margin).IntrinsicSize(ctx, 300, 0)
+		assert.Equal(t, w+60, mw, "Width should include the left and right margins")
+		assert.Equal(t, h+40, mh, "Height should include the top and bottom margins")
+	})
+
+	t.Run("Margined object is offset when drawn", func(t *testing.T) {
+		red := color.RGBA{R: 255, A: 255}
+		m := NewMargined(&solidTile{Size: Size{Width: 10, Height: 10}, Color: red}, margin)
+		ctx := gg.NewContext(100, 100)
+		m.Draw(ctx, 100, 100)
+		assert.Equal(t, color.RGBAModel.Convert(red), ctx.Image().At(40, 10))
+		assert.NotEqual(t, color.RGBAModel.Convert(red), ctx.Image().At(39, 9))
+	})
+}
@@ -0,0 +1,272 @@
+package imacon
+
+import (
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/fogleman/gg"
+)
+
+// codeSpanColor is the faint fill behind `code` spans of markdown text.
+var codeSpanColor = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0x40}
+
+const (
+	fauxBoldWeight  = 1.0 / 24 // The offset of the second copy of faux-bold text, as a fraction of the font size
+	fauxItalicSlant = 0.2      // The horizontal shear of faux-italic text per unit of height
+)
+
+// fontStyle is a set of font variants a span of text is drawn with.
+type fontStyle int
+
+const (
+	styleBold fontStyle = 1 << iota
+	styleItalic
+)
+
+// mdSpan is a run of markdown text drawn with a single style.
+type mdSpan struct {
+	text  string
+	style fontStyle
+	code  bool
+}
+
+// nbsp joins the words of code spans, so that they are never wrapped apart.
+const nbsp = '\u00a0'
+
+// breaksLine reports whether text may be wrapped at the character, any white space but a non-breaking space.
+func breaksLine(r rune) bool {
+	return unicode.IsSpace(r) && r != nbsp
+}
+
+// parseMarkdown splits markdown-lite text into spans of **bold**, *italic* and `code` text, without the markers.
+// Like in Markdown, a marker only opens a span when followed by a non-space character and only closes it when preceded
+// by one, so that e.g. "2 * 3" stays plain. A backslash escapes the next marker or backslash, and spans left open
+// run to the end of the text.
+func parseMarkdown(text string) []mdSpan {
+	var spans []mdSpan
+	var style fontStyle
+	code := false
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			spans = append(spans, mdSpan{text: current.String(), style: style, code: code})
+			current.Reset()
+		}
+	}
+	runes := []rune(text)
+	// flanks reports whether a marker of width n at i can open or close a span
+	flanks := func(i int, n int, open bool) bool {
+		if open {
+			return i+n < len(runes) && !unicode.IsSpace(runes[i+n])
+		}
+		return i > 0 && !unicode.IsSpace(runes[i-1])
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\*`", runes[i+1]):
+			i++
+			current.WriteRune(runes[i])
+		case r == '`' && flanks(i, 1, !code):
+			flush()
+			code = !code
+		case code:
+			current.WriteRune(r)
+		case r == '*' && i+1 < len(runes) && runes[i+1] == '*' && flanks(i, 2, style&styleBold == 0):
+			flush()
+			style ^= styleBold
+			i++
+		case r == '*' && flanks(i, 1, style&styleItalic == 0):
+			flush()
+			style ^= styleItalic
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return spans
+}
+
+// markdownWords rewrites markdown-lite text so that every word carries the markers of its own style, e.g.
+// "**two words**" becomes "**two** **words**", and the spaces within code spans are joined. Each line of the wrapped
+// text then parses on its own, without the state of the lines before it.
+func markdownWords(text string) string {
+	var b strings.Builder
+	for _, span := range parseMarkdown(text) {
+		text := span.text
+		if span.code {
+			text = strings.ReplaceAll(text, " ", string(nbsp))
+		}
+		for _, field := range splitOnSpace(text) {
+			if field == "" || breaksLine([]rune(field)[0]) {
+				b.WriteString(field)
+				continue
+			}
+			b.WriteString(mdSpan{text: field, style: span.style, code: span.code}.markdown())
+		}
+	}
+	return b.String()
+}
+
+// markdown returns the span as markdown text, its text escaped and wrapped in the markers of its style.
+func (s mdSpan) markdown() string {
+	marker := ""
+	if s.code {
+		marker = "`"
+	} else {
+		if s.style&styleBold != 0 {
+			marker += "**"
+		}
+		if s.style&styleItalic != 0 {
+			marker += "*"
+		}
+	}
+	var b strings.Builder
+	b.WriteString(marker)
+	for _, r := range s.text {
+		if strings.ContainsRune("\\*`", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(reverse(marker))
+	return b.String()
+}
+
+// breakMarkdownWord splits a word of markdown text into chunks that each fit within width like breakWord, splitting the
+// text of its spans rather than the markdown itself, so that every chunk carries the markers of its own spans.
+func (t *TextBlock) breakMarkdownWord(ctx *gg.Context, word string, width float64) []string {
+	// format returns the spans as markdown text
+	format := func(spans []mdSpan) string {
+		var b strings.Builder
+		for _, span := range spans {
+			b.WriteString(span.markdown())
+		}
+		return b.String()
+	}
+	// appendRune returns a copy of the spans with the rune appended in the style of the given span
+	appendRune := func(spans []mdSpan, span mdSpan, r rune) []mdSpan {
+		spans = slices.Clone(spans)
+		if n := len(spans); n > 0 && spans[n-1].style == span.style && spans[n-1].code == span.code {
+			spans[n-1].text += string(r)
+			return spans
+		}
+		return append(spans, mdSpan{text: string(r), style: span.style, code: span.code})
+	}
+	var chunks []string
+	var chunk []mdSpan
+	for _, span := range parseMarkdown(word) {
+		for _, r := range span.text {
+			next := appendRune(chunk, span, r)
+			if len(chunk) > 0 && t.measureLine(ctx, format(next)) > width {
+				chunks = append(chunks, format(chunk))
+				next = appendRune(nil, span, r)
+			}
+			chunk = next
+		}
+	}
+	return append(chunks, format(chunk))
+}
+
+// reverse returns the markers in closing order.
+func reverse(marker string) string {
+	runes := []rune(marker)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// spanText returns the text of the span as drawn, with the joined spaces of code spans restored.
+func (s mdSpan) spanText() string {
+	if s.code {
+		return strings.ReplaceAll(s.text, string(nbsp), " ")
+	}
+	return s.text
+}
+
+// withSpanFace calls fn with the face of the span's style set on the context, if the engine has a font for it. fn is
+// passed the part of the style the face lacks, which is synthesized from the regular font when drawing.
+func withSpanFace(ctx *gg.Context, span mdSpan, fn func(faux fontStyle)) {
+	if span.style == 0 {
+		fn(0)
+		return
+	}
+	face, style := styledFaceOf(ctx, ctx.FontHeight(), span.style)
+	if face == nil {
+		fn(span.style)
+		return
+	}
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.SetFontFace(face)
+	fn(span.style &^ style)
+}
+
+// fauxBoldOffset returns how far the second copy of faux-bold text is drawn to the right of the first, which also
+// widens the text by as much.
+func fauxBoldOffset(ctx *gg.Context) float64 {
+	return math.Max(1, ctx.FontHeight()*fauxBoldWeight)
+}
+
+// measureSpan returns the width of a span, widened for faux bold.
+func (t *TextBlock) measureSpan(ctx *gg.Context, span mdSpan, faux fontStyle) float64 {
+	w := t.measureSegment(ctx, span.spanText())
+	if faux&styleBold != 0 {
+		w += fauxBoldOffset(ctx)
+	}
+	return w
+}
+
+// measureMarkdown returns the width of a line of markdown text, each span measured in the face of its style.
+func (t *TextBlock) measureMarkdown(ctx *gg.Context, line string) float64 {
+	w := 0.0
+	for _, span := range parseMarkdown(line) {
+		withSpanFace(ctx, span, func(faux fontStyle) {
+			w += t.measureSpan(ctx, span, faux)
+		})
+	}
+	return w
+}
+
+// drawMarkdown draws a line of markdown text in the current color with its top-left corner at (x, y). Styles without
+// a dedicated font are synthesized: faux bold draws the text twice side by side, and faux italic shears it about
+// the baseline.
+func (t *TextBlock) drawMarkdown(ctx *gg.Context, line string, x float64, y float64) {
+	for _, span := range parseMarkdown(line) {
+		withSpanFace(ctx, span, func(faux fontStyle) {
+			if faux&styleItalic != 0 {
+				ascent, _ := lineMetrics(ctx)
+				ctx.Push()
+				defer ctx.Pop()
+				ctx.ShearAbout(-fauxItalicSlant, 0, x, y+ascent)
+			}
+			t.drawSegment(ctx, span.spanText(), x, y, span.style&^faux)
+			if faux&styleBold != 0 {
+				t.drawSegment(ctx, span.spanText(), x+fauxBoldOffset(ctx), y, span.style&^faux)
+			}
+			x += t.measureSpan(ctx, span, faux)
+		})
+	}
+}
+
+// drawCodeSpans fills the boxes behind the code spans of a line of markdown text with its top-left corner at (x, y).
+func (t *TextBlock) drawCodeSpans(ctx *gg.Context, line string, x float64, y float64) {
+	ascent, descent := lineMetrics(ctx)
+	for _, span := range parseMarkdown(line) {
+		var w float64
+		withSpanFace(ctx, span, func(faux fontStyle) {
+			w = t.measureSpan(ctx, span, faux)
+		})
+		if span.code {
+			ctx.Push()
+			ctx.SetColor(codeSpanColor)
+			fillRect(ctx, x, y, w, ascent+descent)
+			ctx.Pop()
+		}
+		x += w
+	}
+}
@@ -0,0 +1,156 @@
+package imacon
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/gomonobold"
+)
+
+func Test_ParseMarkdown(t *testing.T) {
+	assert.Equal(t, []mdSpan{
+		{text: "A "},
+		{text: "bold", style: styleBold},
+		{text: " and "},
+		{text: "italic", style: styleItalic},
+		{text: " word with "},
+		{text: "some code", code: true},
+	}, parseMarkdown("A **bold** and *italic* word with `some code`"))
+	assert.Equal(t, []mdSpan{{text: "both", style: styleBold | styleItalic}}, parseMarkdown("***both***"))
+	assert.Equal(t, []mdSpan{{text: "2 * 3 = 6"}}, parseMarkdown("2 * 3 = 6"), "Markers next to spaces should stay literal")
+	assert.Equal(t, []mdSpan{{text: "*not italic*"}}, parseMarkdown(`\*not italic\*`), "Escaped markers should stay literal")
+	assert.Equal(t, []mdSpan{{text: "**", code: true}}, parseMarkdown("`**`"), "Code spans should not be parsed")
+
+	words := markdownWords("**two words** and `a b` with \\*")
+	assert.Equal(t, "**two** **words** and `a\u00a0b` with \\*", words, "Every word should carry its own markers")
+	text := ""
+	for _, span := range parseMarkdown(words) {
+		text += span.spanText()
+	}
+	assert.Equal(t, "two words and a b with *", text, "The rewritten text should draw the same characters")
+}
+
+func Test_TextBlockMarkdown(t *testing.T) {
+	eng := New(Config{BoldFont: gomonobold.TTF})
+	face, err := eng.fontFace()
+	require.NoError(t, err)
+	// newCtx returns a context rendered by the engine, so that the bold font is available
+	newCtx := func() *gg.Context {
+		ctx := gg.NewContext(400, 60)
+		ctx.SetFontFace(face)
+		renderStates.Store(ctx, eng.newRenderState())
+		t.Cleanup(func() { renderStates.Delete(ctx) })
+		ctx.SetColor(color.White)
+		ctx.Clear()
+		ctx.SetColor(color.Black)
+		return ctx
+	}
+	// ink measures how dark the drawn text is
+	ink := func(ctx *gg.Context) float64 {
+		total := 0.0
+		bounds := ctx.Image().Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, _, _, _ := ctx.Image().At(x, y).RGBA()
+				total += float64(0xffff-r) / 0xffff
+			}
+		}
+		return total
+	}
+
+	plain := NewTextBlock("Heavy words", TextBlockOpts{Markdown: true})
+	bold := NewTextBlock("**Heavy words**", TextBlockOpts{Markdown: true})
+	plainCtx, boldCtx := newCtx(), newCtx()
+	plainW, plainH := plain.IntrinsicSize(plainCtx, 0, 0)
+	boldW, boldH := bold.IntrinsicSize(boldCtx, 0, 0)
+	assert.Equal(t, plainH, boldH)
+	assert.InDelta(t, plainW, boldW, 1, "The markers should not be measured, and both fonts are monospace")
+	plain.Draw(plainCtx, 400, 60)
+	bold.Draw(boldCtx, 400, 60)
+	assert.Greater(t, ink(boldCtx), ink(plainCtx)*1.2, "Bold spans should be drawn heavier than plain text")
+
+	raw := NewTextBlock("**Heavy words**", TextBlockOpts{})
+	rawW, _ := raw.IntrinsicSize(newCtx(), 0, 0)
+	assert.Greater(t, rawW, boldW, "Markers should be drawn as text without Markdown")
+
+	// words of a span wrapped onto several lines keep their style
+	wrapped := NewTextBlock("Some **bold words that wrap around** here", TextBlockOpts{Markdown: true, TextWrap: true})
+	lines := wrapped.wrap(newCtx(), 12*8)
+	require.Greater(t, len(lines), 2)
+	for _, line := range lines[1 : len(lines)-1] {
+		assert.True(t, strings.HasPrefix(line, "**"), "Line %q should open its bold words", line)
+	}
+}
+
+func Test_TextBlockFauxStyles(t *testing.T) {
+	face, err := New(Config{}).fontFace()
+	require.NoError(t, err)
+	// draw returns a context with the markdown text drawn without bold and italic fonts, and its width
+	draw := func(text string) (*gg.Context, float64) {
+		ctx := gg.NewContext(400, 60)
+		ctx.SetFontFace(face)
+		ctx.SetColor(color.White)
+		ctx.Clear()
+		ctx.SetColor(color.Black)
+		block := NewTextBlock(text, TextBlockOpts{Markdown: true})
+		w, _ := block.IntrinsicSize(ctx, 0, 0)
+		block.Draw(ctx, 400, 60)
+		return ctx, w
+	}
+	// coverage returns the total ink of the rows in [y0, y1), and its mean x
+	coverage := func(ctx *gg.Context, y0 int, y1 int) (float64, float64) {
+		total, moment := 0.0, 0.0
+		for y := y0; y < y1; y++ {
+			for x := 0; x < ctx.Width(); x++ {
+				r, _, _, _ := ctx.Image().At(x, y).RGBA()
+				ink := float64(0xffff-r) / 0xffff
+				total += ink
+				moment += ink * float64(x)
+			}
+		}
+		return total, moment / total
+	}
+
+	plainCtx, plainW := draw("Heavy words")
+	boldCtx, boldW := draw("**Heavy words**")
+	plainInk, _ := coverage(plainCtx, 0, 60)
+	boldInk, _ := coverage(boldCtx, 0, 60)
+	assert.Greater(t, boldInk, plainInk*1.2, "Faux-bold glyphs should cover more than regular ones")
+	assert.Greater(t, boldW, plainW, "Faux bold should be measured with its offset copy")
+
+	// the top of faux-italic glyphs leans to the right of their bottom
+	italicCtx, italicW := draw("*Heavy words*")
+	assert.Equal(t, plainW, italicW)
+	ascent, _ := lineMetrics(italicCtx)
+	half := int(ascent / 2)
+	_, plainTop := coverage(plainCtx, 0, half)
+	_, plainBottom := coverage(plainCtx, half, int(ascent))
+	_, italicTop := coverage(italicCtx, 0, half)
+	_, italicBottom := coverage(italicCtx, half, int(ascent))
+	assert.Greater(t, (italicTop-italicBottom)-(plainTop-plainBottom), 1.0, "Faux italic should be sheared")
+}
+
+func Test_TextBlockMarkdownBreakLongWords(t *testing.T) {
+	block := NewTextBlock("A **Supercalifragilistic\\*** word", TextBlockOpts{Markdown: true, TextWrap: true, BreakLongWords: true})
+	ctx := gg.NewContext(1, 1)
+	face, err := New(Config{}).fontFace()
+	require.NoError(t, err)
+	ctx.SetFontFace(face)
+	width := block.measureLine(ctx, "**Supercal**")
+	lines := block.wrap(ctx, width)
+	require.Greater(t, len(lines), 3, "The bold word should be broken up")
+
+	text := ""
+	for _, line := range lines[1 : len(lines)-1] {
+		assert.LessOrEqual(t, block.measureLine(ctx, line), width, "Line %q should fit", line)
+		spans := parseMarkdown(line)
+		require.Len(t, spans, 1, "Line %q should be a single span", line)
+		assert.Equal(t, styleBold, spans[0].style, "Every chunk of the bold word should stay bold")
+		text += spans[0].text
+	}
+	assert.Equal(t, "Supercalifragilistic*", text, "The chunks should keep the text of the word, including escaped markers")
+}
@@ -0,0 +1,204 @@
+package imacon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// metadata holds the descriptive fields and the physical resolution embedded into the encoded canvas output.
+type metadata struct {
+	Title       string
+	Description string
+	Created     time.Time
+	DPI         int // The resolution in dots per inch, or zero to leave it unspecified
+}
+
+func (m metadata) empty() bool {
+	return !m.hasText() && m.DPI <= 0
+}
+
+// hasText reports whether any of the descriptive fields is set.
+func (m metadata) hasText() bool {
+	return m.Title != "" || m.Description != "" || !m.Created.IsZero()
+}
+
+// The software name written into the encoded output metadata.
+const metadataSoftware = "imacon"
+
+// pngSignature is the 8-byte header that starts every PNG stream.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngChunk encodes a single PNG chunk with its length prefix and CRC.
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 0, len(data)+12)
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// pngTextChunk encodes a keyword/text pair, using tEXt for Latin-1 text and iTXt for anything else.
+func pngTextChunk(keyword string, text string) []byte {
+	latin1 := true
+	for _, r := range text {
+		if r > 0xff {
+			latin1 = false
+			break
+		}
+	}
+	if latin1 {
+		data := append([]byte(keyword), 0)
+		for _, r := range text {
+			data = append(data, byte(r))
+		}
+		return pngChunk("tEXt", data)
+	}
+	// keyword, null, compression flag, compression method, empty language tag and translated keyword, UTF-8 text
+	data := append([]byte(keyword), 0, 0, 0, 0, 0)
+	data = append(data, text...)
+	return pngChunk("iTXt", data)
+}
+
+// pngChunks returns the pHYs chunk for the resolution, if set, followed by the text chunks for the non-empty
+// metadata fields.
+func (m metadata) pngChunks() [][]byte {
+	var chunks [][]byte
+	if m.DPI > 0 {
+		// pixels per metre on both axes, then the metre unit
+		ppm := uint32(math.Round(float64(m.DPI) / 0.0254))
+		data := binary.BigEndian.AppendUint32(nil, ppm)
+		data = binary.BigEndian.AppendUint32(data, ppm)
+		chunks = append(chunks, pngChunk("pHYs", append(data, 1)))
+	}
+	if m.Title != "" {
+		chunks = append(chunks, pngTextChunk("Title", m.Title))
+	}
+	if m.Description != "" {
+		chunks = append(chunks, pngTextChunk("Description", m.Description))
+	}
+	if !m.Created.IsZero() {
+		chunks = append(chunks, pngTextChunk("Creation Time", m.Created.Format(time.RFC1123Z)))
+	}
+	return chunks
+}
+
+// writePngWithChunks writes the encoded PNG to the writer with the extra chunks inserted right after the IHDR chunk.
+func writePngWithChunks(writer io.Writer, encoded []byte, chunks [][]byte) error {
+	// the IHDR chunk always comes first: 8 bytes of length and type, 13 bytes of data, 4 bytes of CRC
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	var buf bytes.Buffer
+	buf.Write(encoded[:ihdrEnd])
+	for _, chunk := range chunks {
+		buf.Write(chunk)
+	}
+	buf.Write(encoded[ihdrEnd:])
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
+
+// EXIF tags written into the JPEG APP1 segment.
+const (
+	exifTagImageDescription = 0x010e
+	exifTagSoftware         = 0x0131
+	exifTagDateTime         = 0x0132
+	exifTypeASCII           = 2
+)
+
+// jpegSegments returns the JFIF APP0 segment for the resolution, if set, followed by the EXIF segment when any
+// descriptive field is set.
+func (m metadata) jpegSegments() ([][]byte, error) {
+	var segments [][]byte
+	if m.DPI > 0 {
+		segments = append(segments, m.jpegJfifSegment())
+	}
+	if m.hasText() {
+		exif, err := m.jpegExifSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, exif)
+	}
+	return segments, nil
+}
+
+// jpegJfifSegment encodes the resolution as a JFIF APP0 segment, with the density clamped to the 16 bits it is stored in.
+func (m metadata) jpegJfifSegment() []byte {
+	density := uint16(min(m.DPI, math.MaxUint16))
+	// identifier, version 1.02, dots per inch unit, horizontal and vertical density, no thumbnail
+	payload := append([]byte("JFIF\x00"), 1, 2, 1)
+	payload = binary.BigEndian.AppendUint16(payload, density)
+	payload = binary.BigEndian.AppendUint16(payload, density)
+	// the fixed-size payload always fits
+	segment, _ := jpegSegment(0xe0, append(payload, 0, 0))
+	return segment
+}
+
+// jpegExifSegment encodes the metadata as an EXIF APP1 segment holding a single big-endian IFD of ASCII tags.
+// EXIF cannot span segments, so the description and title must fit into the 64 KiB of a single one.
+func (m metadata) jpegExifSegment() ([]byte, error) {
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	// IFD entries must be sorted by tag
+	var entries []entry
+	if m.Description != "" {
+		entries = append(entries, entry{exifTagImageDescription, m.Description})
+	} else if m.Title != "" {
+		entries = append(entries, entry{exifTagImageDescription, m.Title})
+	}
+	entries = append(entries, entry{exifTagSoftware, metadataSoftware})
+	if !m.Created.IsZero() {
+		entries = append(entries, entry{exifTagDateTime, m.Created.Format("2006:01:02 15:04:05")})
+	}
+
+	// TIFF header, then the IFD, then the values too large to be stored inline
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8}
+	dataOffset := 8 + 2 + len(entries)*12 + 4
+	var data []byte
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		value := append([]byte(e.value), 0)
+		tiff = binary.BigEndian.AppendUint16(tiff, e.tag)
+		tiff = binary.BigEndian.AppendUint16(tiff, exifTypeASCII)
+		tiff = binary.BigEndian.AppendUint32(tiff, uint32(len(value)))
+		if len(value) <= 4 {
+			tiff = append(tiff, append(value, make([]byte, 4-len(value))...)...)
+		} else {
+			tiff = binary.BigEndian.AppendUint32(tiff, uint32(dataOffset+len(data)))
+			data = append(data, value...)
+		}
+	}
+	tiff = binary.BigEndian.AppendUint32(tiff, 0) // no next IFD
+	tiff = append(tiff, data...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	return jpegSegment(0xe1, payload)
+}
+
+// jpegSegment encodes a JPEG marker segment with its length prefix, which counts itself and is limited to 16 bits.
+func jpegSegment(marker byte, payload []byte) ([]byte, error) {
+	if len(payload) > math.MaxUint16-2 {
+		return nil, fmt.Errorf("imacon: JPEG segment payload of %d bytes exceeds the %d byte limit", len(payload), math.MaxUint16-2)
+	}
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...), nil
+}
+
+// writeJpegWithSegments writes the encoded JPEG to the writer with the extra segments inserted right after the SOI marker.
+func writeJpegWithSegments(writer io.Writer, encoded []byte, segments [][]byte) error {
+	var buf bytes.Buffer
+	buf.Write(encoded[:2])
+	for _, segment := range segments {
+		buf.Write(segment)
+	}
+	buf.Write(encoded[2:])
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,136 @@
+package imacon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PngMetadata(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	t.Run("Text chunks", func(t *testing.T) {
+		scene := NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+		scene.Title = "Greeting"
+		scene.Description = "A plain text greeting — rendered by imacon"
+
+		c, err := eng.Render(scene)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, c.ToPng(&buf))
+
+		assert.Contains(t, buf.String(), "tEXtTitle\x00Greeting", "Latin-1 title should be written as a tEXt chunk")
+		assert.Contains(t, buf.String(), "iTXtDescription\x00\x00\x00\x00\x00"+scene.Description, "UTF-8 description should be written as an iTXt chunk")
+
+		_, err = png.Decode(&buf)
+		assert.NoError(t, err, "PNG with metadata chunks should still decode")
+	})
+
+	t.Run("No chunks without metadata", func(t *testing.T) {
+		scene := NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+		c, err := eng.Render(scene)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, c.ToPng(&buf))
+		assert.NotContains(t, buf.String(), "tEXt")
+		assert.NotContains(t, buf.String(), "iTXt")
+	})
+}
+
+// readExifTags parses the ASCII tags of the first IFD in a JPEG's EXIF APP1 segment.
+func readExifTags(t *testing.T, data []byte) map[uint16]string {
+	t.Helper()
+	require.Equal(t, []byte{0xff, 0xd8, 0xff, 0xe1}, data[:4], "EXIF segment should follow the SOI marker")
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	payload := data[6 : 4+length]
+	require.Equal(t, "Exif\x00\x00", string(payload[:6]))
+
+	tiff := payload[6:]
+	require.Equal(t, "MM", string(tiff[:2]))
+	ifd := int(binary.BigEndian.Uint32(tiff[4:8]))
+	count := int(binary.BigEndian.Uint16(tiff[ifd:]))
+	tags := make(map[uint16]string)
+	for i := range count {
+		entry := tiff[ifd+2+i*12:]
+		n := int(binary.BigEndian.Uint32(entry[4:8]))
+		value := entry[8 : 8+n]
+		if n > 4 {
+			offset := int(binary.BigEndian.Uint32(entry[8:12]))
+			value = tiff[offset : offset+n]
+		}
+		tags[binary.BigEndian.Uint16(entry[:2])] = strings.TrimRight(string(value), "\x00")
+	}
+	return tags
+}
+
+func Test_JpegMetadata(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	scene := NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+	scene.Description = "A plain text greeting"
+	scene.Created = time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	c, err := eng.Render(scene)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, c.ToJpeg(&buf, nil))
+
+	tags := readExifTags(t, buf.Bytes())
+	assert.Equal(t, "A plain text greeting", tags[exifTagImageDescription])
+	assert.Equal(t, "imacon", tags[exifTagSoftware])
+	assert.Equal(t, "2025:03:14 15:09:26", tags[exifTagDateTime])
+
+	_, err = jpeg.Decode(&buf)
+	assert.NoError(t, err, "JPEG with an EXIF segment should still decode")
+
+	scene.Description = strings.Repeat("a", 1<<16)
+	c, err = eng.Render(scene)
+	require.NoError(t, err)
+	assert.ErrorContains(t, c.ToJpeg(io.Discard, nil), "exceeds", "A description too long for an EXIF segment should fail rather than corrupt the JPEG")
+}
+
+func Test_OutputDPI(t *testing.T) {
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+	}
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, OutputDPI: 300})
+	c, err := eng.Render(newScene())
+	require.NoError(t, err)
+
+	// pHYs holds the pixels per metre on both axes and the metre unit
+	wantPhys := pngChunk("pHYs", []byte{0, 0, 0x2e, 0x23, 0, 0, 0x2e, 0x23, 1})
+	var buf bytes.Buffer
+	require.NoError(t, c.ToPng(&buf))
+	assert.True(t, bytes.Contains(buf.Bytes(), wantPhys), "PNG should declare 11811 pixels per metre for 300 DPI")
+	_, err = png.Decode(&buf)
+	assert.NoError(t, err)
+
+	buf.Reset()
+	require.NoError(t, eng.RenderTo(newScene(), &buf, FormatPNG))
+	assert.True(t, bytes.Contains(buf.Bytes(), wantPhys), "Streamed PNG should declare the resolution too")
+
+	buf.Reset()
+	require.NoError(t, c.ToJpeg(&buf, nil))
+	data := buf.Bytes()
+	require.Equal(t, []byte{0xff, 0xd8, 0xff, 0xe0}, data[:4], "JFIF segment should follow the SOI marker")
+	assert.Equal(t, "JFIF\x00", string(data[6:11]))
+	assert.Equal(t, byte(1), data[13], "Density should be in dots per inch")
+	assert.Equal(t, uint16(300), binary.BigEndian.Uint16(data[14:16]))
+	assert.Equal(t, uint16(300), binary.BigEndian.Uint16(data[16:18]))
+	assert.NotContains(t, buf.String(), "Exif\x00\x00", "No EXIF segment should be written without descriptive metadata")
+	_, err = jpeg.Decode(&buf)
+	assert.NoError(t, err)
+
+	c, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(newScene())
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, c.ToPng(&buf))
+	assert.NotContains(t, buf.String(), "pHYs", "No resolution should be written without OutputDPI")
+}
@@ -0,0 +1,36 @@
+package imacon
+
+import "fmt"
+
+// RenderPaginated splits the objects of the scene's main pane into pages of at most maxPerPage objects and renders
+// each page as its own canvas, in order. Every page keeps the layout parameters of the main pane, as well as the
+// header, footer, overlays and metadata of the scene. A main pane holding its objects only in a planned shape, as made
+// by NewPaneWithShape, SceneBuilder and NewContactSheet, is paginated in the column order of the shape, and each page is
+// laid out afresh.
+func (e *Engine) RenderPaginated(scene *Scene, maxPerPage int) ([]*Canvas, error) {
+	if maxPerPage <= 0 {
+		return nil, fmt.Errorf("imacon: max objects per page must be positive, got %d", maxPerPage)
+	}
+	if scene == nil || scene.Main == nil {
+		return nil, fmt.Errorf("imacon: scene has no Main pane")
+	}
+	objects := scene.Main.Objects
+	if len(objects) == 0 && scene.Main.PlannedShape != nil {
+		objects = scene.Main.PlannedShape.objects()
+	}
+	var canvases []*Canvas
+	for start := 0; start < len(objects); start += maxPerPage {
+		main := *scene.Main
+		main.Objects = objects[start:min(start+maxPerPage, len(objects))]
+		main.PlannedShape = nil
+		page := *scene
+		page.Main = &main
+
+		canvas, err := e.Render(&page)
+		if err != nil {
+			return nil, err
+		}
+		canvases = append(canvases, canvas)
+	}
+	return canvases, nil
+}
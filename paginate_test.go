@@ -0,0 +1,52 @@
+package imacon
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenderPaginated(t *testing.T) {
+	objects := make([]Tileable, 10)
+	for i := range objects {
+		objects[i] = loadImageBlock(t, "assets/samples/sample_1.jpg", fmt.Sprintf("Sample %d", i+1))
+	}
+	scene := NewScene(NewPane(objects, 0, 0, 0))
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+
+	pages, err := eng.RenderPaginated(scene, 4)
+	require.NoError(t, err)
+	require.Len(t, pages, 3, "10 objects with 4 per page should produce 3 canvases")
+
+	// the pages hold the objects in order, which shows in the order of their layouts
+	var seen []Tileable
+	for _, page := range pages {
+		for _, column := range page.Layout.Shape.Columns {
+			assert.LessOrEqual(t, len(column.Objects), 4)
+		}
+		var pageObjects []Tileable
+		for _, column := range page.Layout.Shape.Columns {
+			pageObjects = append(pageObjects, column.Objects...)
+		}
+		assert.ElementsMatch(t, objects[len(seen):len(seen)+len(pageObjects)], pageObjects, "Pages should preserve the object order")
+		seen = append(seen, pageObjects...)
+	}
+	assert.Len(t, seen, len(objects))
+	assert.Nil(t, scene.Main.PlannedShape, "The original pane should be left untouched")
+
+	_, err = eng.RenderPaginated(scene, 0)
+	assert.Error(t, err)
+
+	builder := NewSceneBuilder().Column()
+	for _, obj := range objects[:5] {
+		builder.Add(obj)
+	}
+	built, err := builder.EndColumn().Build()
+	require.NoError(t, err)
+	pages, err = eng.RenderPaginated(built, 2)
+	require.NoError(t, err)
+	require.Len(t, pages, 3, "The objects of a planned shape should be paginated")
+	assert.Same(t, objects[4], pages[2].Layout.Shape.Columns[0].Objects[0])
+}
@@ -0,0 +1,88 @@
+package imacon
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// mainPanes returns the panes making up the body of the scene, either the main pane or the panes side by side.
+func (s *Scene) mainPanes() []*Pane {
+	if s.Main != nil {
+		return []*Pane{s.Main}
+	}
+	return s.Panes
+}
+
+// body returns the tileable laid out between the header and footer bands.
+func (s *Scene) body() Tileable {
+	if s.Main != nil {
+		return s.Main
+	}
+	return &paneRow{panes: s.Panes, widths: s.PaneWidths}
+}
+
+// paneRow lays out the top-level panes of a scene side by side, each in a slot of its relative width.
+type paneRow struct {
+	panes  []*Pane
+	widths []float64
+}
+
+// weight returns the relative width of the pane at index i, defaulting to 1.
+func (r *paneRow) weight(i int) float64 {
+	if i < len(r.widths) && r.widths[i] > 0 {
+		return r.widths[i]
+	}
+	return 1
+}
+
+// totalWeight returns the sum of the relative widths of all panes.
+func (r *paneRow) totalWeight() float64 {
+	total := 0.0
+	for i := range r.panes {
+		total += r.weight(i)
+	}
+	return total
+}
+
+// setMaxWidth splits the width budget of the row between the panes by their relative widths.
+func (r *paneRow) setMaxWidth(maxWidth float64) {
+	available := maxWidth - DefaultColPad*float64(len(r.panes)-1)
+	for i, pane := range r.panes {
+		pane.maxWidth = available * r.weight(i) / r.totalWeight()
+	}
+}
+
+// slotWidths returns the width of each pane's slot, scaled so that every pane fits within its slot
+// while the slots keep their relative widths.
+func (r *paneRow) slotWidths(ctx *gg.Context) []float64 {
+	unit := 0.0
+	for i, pane := range r.panes {
+		w, _ := pane.IntrinsicSize(ctx, 0, 0)
+		unit = math.Max(unit, w/r.weight(i))
+	}
+	slots := make([]float64, len(r.panes))
+	for i := range r.panes {
+		slots[i] = unit * r.weight(i)
+	}
+	return slots
+}
+
+func (r *paneRow) Draw(ctx *gg.Context, cw float64, ch float64) {
+	ctx.Push()
+	for i, slot := range r.slotWidths(ctx) {
+		r.panes[i].Draw(ctx, slot, ch)
+		ctx.Translate(slot+DefaultColPad, 0)
+	}
+	ctx.Pop()
+}
+
+func (r *paneRow) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	width, height := DefaultColPad*float64(len(r.panes)-1), 0.0
+	for i, slot := range r.slotWidths(ctx) {
+		_, h := r.panes[i].IntrinsicSize(ctx, 0, 0)
+		width += slot
+		height = math.Max(height, h)
+	}
+	return width, height
+}
@@ -0,0 +1,33 @@
+package imacon
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ScenePanes(t *testing.T) {
+	sidebarObj := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 80, 200)), "")
+	contentObj := NewImageBlockFromImage(image.NewRGBA(image.Rect(0, 0, 180, 100)), "")
+	sidebar := NewPane([]Tileable{sidebarObj}, 100, 0, 0)
+	content := NewPane([]Tileable{contentObj}, 200, 0, 0)
+	scene := &Scene{Panes: []*Pane{sidebar, content}, PaneWidths: []float64{1, 3}}
+
+	result, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).RenderWithRegions(scene)
+	require.NoError(t, err)
+
+	// the sidebar is the widest relative to its weight, so it sets the slot unit: 100 and 3 * 100 wide
+	pad := int(DefaultOuterPad)
+	assert.Equal(t, 100+int(DefaultColPad)+300+2*pad, result.Canvas.Width, "The slots should keep the 1:3 width ratio")
+	assert.Equal(t, pad, result.Regions[sidebarObj].Min.X, "The sidebar should be drawn in the first slot")
+	assert.Equal(t, pad+100+int(DefaultColPad), result.Regions[contentObj].Min.X, "The content pane should start after the sidebar slot")
+	assert.Equal(t, pad, result.Regions[contentObj].Min.Y, "The panes should be top-aligned in a row")
+	assert.Greater(t, result.Canvas.Height, 200+2*pad-1, "The row should be as tall as the tallest pane")
+
+	_, err = New(Config{}).Render(&Scene{Main: sidebar, Panes: []*Pane{content}})
+	assert.Error(t, err, "Scenes should not have both a main pane and panes")
+	_, err = New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096}).Render(&Scene{Panes: []*Pane{sidebar, nil}})
+	assert.ErrorContains(t, err, "scene pane 1 is nil", "Nil panes should be rejected rather than panic")
+}
@@ -0,0 +1,87 @@
+package imacon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ImageSource describes an encoded image to decode into an image block with NewImageBlocksParallel.
+type ImageSource struct {
+	Reader io.Reader // The encoded image. When nil, the image is read from Path.
+	Path   string    // The file holding the encoded image, opened by the worker decoding it
+	Label  string    // The label of the image block
+}
+
+// open returns the reader of the encoded image, and the file to close after decoding if it was opened from Path.
+func (s ImageSource) open() (io.Reader, io.Closer, error) {
+	if s.Reader != nil {
+		return s.Reader, nil, nil
+	}
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file, nil
+}
+
+// decode decodes the source into an image block.
+func (s ImageSource) decode() (*ImageBlock, error) {
+	reader, closer, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	return NewImageBlock(reader, s.Label)
+}
+
+// NewImageBlocksParallel decodes the sources into image blocks like NewImageBlock, with up to concurrency images
+// decoded at once, and returns the blocks in the order of the sources. Zero or negative concurrency uses
+// runtime.GOMAXPROCS. Once a source fails, no further sources are started, and the error of the first failed source
+// in input order is returned.
+func NewImageBlocksParallel(sources []ImageSource, concurrency int) ([]*ImageBlock, error) {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(sources))
+
+	blocks := make([]*ImageBlock, len(sources))
+	errs := make([]error, len(sources))
+	indices := make(chan int)
+	done := make(chan struct{})
+	var failOnce sync.Once
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				blocks[i], errs[i] = sources[i].decode()
+				if errs[i] != nil {
+					failOnce.Do(func() { close(done) })
+				}
+			}
+		}()
+	}
+dispatch:
+	for i := range sources {
+		select {
+		case indices <- i:
+		case <-done:
+			break dispatch
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("imacon: failed to decode image %d: %w", i, err)
+		}
+	}
+	return blocks, nil
+}
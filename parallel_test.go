@@ -0,0 +1,75 @@
+package imacon
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowReader delays its first read, standing in for an image that is slow to load.
+type slowReader struct {
+	io.Reader
+	delay time.Duration
+	slept bool
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if !r.slept {
+		time.Sleep(r.delay)
+		r.slept = true
+	}
+	return r.Reader.Read(p)
+}
+
+func Test_NewImageBlocksParallel(t *testing.T) {
+	const count = 16
+	const delay = 20 * time.Millisecond
+	// each image is as wide as its index plus one, so that the order can be checked
+	newSources := func() []ImageSource {
+		sources := make([]ImageSource, count)
+		for i := range sources {
+			var buf bytes.Buffer
+			require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, i+1, 1))))
+			sources[i] = ImageSource{Reader: &slowReader{Reader: &buf, delay: delay}, Label: strconv.Itoa(i)}
+		}
+		return sources
+	}
+
+	start := time.Now()
+	blocks, err := NewImageBlocksParallel(newSources(), 8)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	require.Len(t, blocks, count)
+	for i, block := range blocks {
+		assert.Equal(t, i+1, block.Image.Bounds().Dx(), "Blocks should be in the order of the sources")
+		assert.Equal(t, strconv.Itoa(i), block.Label.Text)
+	}
+	assert.Less(t, elapsed, count*delay/2, "Decoding in parallel should be faster than one after another")
+
+	t.Run("From paths", func(t *testing.T) {
+		blocks, err := NewImageBlocksParallel([]ImageSource{
+			{Path: "assets/samples/sample_1.jpg", Label: "Face"},
+			{Path: "assets/samples/glasses.png", Label: "Glasses"},
+		}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "Face", blocks[0].Label.Text)
+		assert.Equal(t, "Glasses", blocks[1].Label.Text)
+	})
+
+	t.Run("First error", func(t *testing.T) {
+		sources := newSources()
+		sources[3].Reader = strings.NewReader("not an image")
+		sources[9].Path, sources[9].Reader = "assets/samples/missing.png", nil
+		_, err := NewImageBlocksParallel(sources, 4)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "image 3", "The error of the first failed source should be returned")
+	})
+}
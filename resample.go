@@ -0,0 +1,54 @@
+package imacon
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Resampling selects the kernel used to resize images to their drawn size.
+type Resampling int
+
+const (
+	ResampleDefault         Resampling = iota // Scale the image while drawing with gg's bilinear sampling
+	ResampleNearestNeighbor                   // Pre-resize with nearest-neighbor sampling, the fastest but most aliased
+	ResampleBilinear                          // Pre-resize with a bilinear kernel
+	ResampleCatmullRom                        // Pre-resize with a Catmull-Rom kernel, sharper than bilinear
+	ResampleLanczos                           // Pre-resize with a Lanczos-3 kernel, the sharpest and slowest
+)
+
+// lanczos3 is the Lanczos kernel with a support of 3, which golang.org/x/image/draw doesn't provide.
+var lanczos3 = &draw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	if t >= 3 {
+		return 0
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}}
+
+// interpolator returns the interpolator of the resampling mode, or nil for the default mode.
+func (r Resampling) interpolator() draw.Interpolator {
+	switch r {
+	case ResampleNearestNeighbor:
+		return draw.NearestNeighbor
+	case ResampleBilinear:
+		return draw.BiLinear
+	case ResampleCatmullRom:
+		return draw.CatmullRom
+	case ResampleLanczos:
+		return lanczos3
+	default:
+		return nil
+	}
+}
+
+// resizeImage scales the whole image to the given size with the interpolator.
+func resizeImage(img image.Image, size image.Point, interpolator draw.Interpolator) image.Image {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	interpolator.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,70 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ImageBlockResampling(t *testing.T) {
+	// one pixel stripes average out to mid gray when downscaled, but alias badly with nearest-neighbor sampling
+	stripes := image.NewGray(image.Rect(0, 0, 800, 800))
+	for y := range 800 {
+		for x := range 800 {
+			if (x+y/3)%2 == 0 {
+				stripes.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	// meanSquaredError draws the stripes downscaled to 200x200 and compares them with the mid gray reference
+	meanSquaredError := func(resampling Resampling) float64 {
+		block := NewImageBlockFromImage(stripes, "")
+		block.Opts.Resampling = resampling
+		ctx := gg.NewContext(200, 200)
+		block.Draw(ctx, 200, 200)
+		sum := 0.0
+		for y := range 200 {
+			for x := range 200 {
+				r, _, _, _ := ctx.Image().At(x, y).RGBA()
+				d := float64(r>>8) - 127.5
+				sum += d * d
+			}
+		}
+		return sum / (200 * 200)
+	}
+
+	nearest := meanSquaredError(ResampleNearestNeighbor)
+	for _, resampling := range []Resampling{ResampleBilinear, ResampleCatmullRom, ResampleLanczos} {
+		assert.Less(t, meanSquaredError(resampling), nearest/4, "Resampling mode %d should alias less than nearest-neighbor", resampling)
+	}
+}
+
+func Test_ImageBlockResampledBounds(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	block := NewImageBlockFromImage(img, "")
+	block.Opts.Resampling = ResampleLanczos
+
+	// a fractional offset should shift the resized image rather than stretch it a pixel past the drawn size
+	ctx := gg.NewContext(200, 200)
+	ctx.Translate(10.5, 10.5)
+	block.drawResized(ctx, block.Opts.Resampling.interpolator())
+	assert.Equal(t, image.Pt(100, 100), block.resized.Bounds().Size())
+
+	// engines drawing scenes that share the block at different sizes must not race on the cache
+	var wg sync.WaitGroup
+	for n := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := gg.NewContext(200, 200)
+			ctx.Scale(1+float64(n)/8, 1)
+			block.drawResized(ctx, block.Opts.Resampling.interpolator())
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,41 @@
+package imacon
+
+import "time"
+
+// RenderStats describes how a canvas was rendered, e.g. for tuning the max canvas size and spotting slow scenes.
+type RenderStats struct {
+	LayoutTime time.Duration // The time spent measuring the scene and planning the canvas
+	DrawTime   time.Duration // The time spent drawing the planned scene
+	Objects    int           // The number of objects in the panes of the scene, counting the objects of nested panes too
+	Columns    int           // The number of columns the main pane was laid out in, or those of all top-level Panes together
+	// The logical size of the content before it was clamped to the max canvas size or fitted into the fixed size.
+	MeasuredWidth  int
+	MeasuredHeight int
+	// The logical size of the canvas, before the output Scale is applied.
+	Width  int
+	Height int
+	// Whether the content was scaled down to fit within the max canvas size or the fixed size.
+	Clamped bool
+}
+
+// countObjects returns the number of objects in the header, footer and main panes of the scene, including nested panes.
+func (s *Scene) countObjects() int {
+	count := 0
+	for _, pane := range append([]*Pane{s.Header, s.Footer}, s.mainPanes()...) {
+		if pane != nil {
+			count += pane.countObjects()
+		}
+	}
+	return count
+}
+
+// countColumns returns the number of columns the main panes of the scene were laid out in.
+func (s *Scene) countColumns() int {
+	count := 0
+	for _, pane := range s.mainPanes() {
+		if pane.PlannedShape != nil {
+			count += len(pane.PlannedShape.Columns)
+		}
+	}
+	return count
+}
@@ -0,0 +1,49 @@
+package imacon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenderStats(t *testing.T) {
+	newScene := func() *Scene {
+		nested := NewPane([]Tileable{
+			NewTextBlock("First", TextBlockOpts{}),
+			NewTextBlock("Second", TextBlockOpts{}),
+		}, 0, 0, 0)
+		scene := NewScene(NewPaneWithCols([]Tileable{
+			loadImageBlock(t, "assets/samples/sample_1.jpg", "Face"),
+			loadImageBlock(t, "assets/samples/sample_2.jpg", "Portrait"),
+			loadImageBlock(t, "assets/samples/glasses.png", "Glasses"),
+			nested,
+		}, 2, 0, 0, 0))
+		scene.Header = NewPane([]Tileable{NewTextBlock("Title", TextBlockOpts{})}, 0, 0, 0)
+		return scene
+	}
+
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096})
+	c, err := eng.Render(newScene())
+	require.NoError(t, err)
+	stats := c.Stats
+	assert.Equal(t, 7, stats.Objects, "Objects should count the header, main and nested pane objects")
+	assert.Equal(t, 2, stats.Columns)
+	assert.Equal(t, len(c.Layout.Shape.Columns), stats.Columns, "Columns should match the drawn layout")
+	assert.Equal(t, c.Width, stats.Width)
+	assert.Equal(t, c.Height, stats.Height)
+	assert.Equal(t, stats.Width, stats.MeasuredWidth)
+	assert.False(t, stats.Clamped)
+	assert.Positive(t, stats.LayoutTime)
+	assert.Positive(t, stats.DrawTime)
+
+	measuredW, measuredH, clamped, err := New(Config{MaxCanvasWidth: 400, MaxCanvasHeight: 4096}).Measure(newScene())
+	require.NoError(t, err)
+	require.True(t, clamped)
+	c, err = New(Config{MaxCanvasWidth: 400, MaxCanvasHeight: 4096}).Render(newScene())
+	require.NoError(t, err)
+	assert.True(t, c.Stats.Clamped, "Clamping to the max canvas width should be reported")
+	assert.Equal(t, measuredW, c.Stats.MeasuredWidth, "The measured size should be the size before clamping")
+	assert.Equal(t, measuredH, c.Stats.MeasuredHeight)
+	assert.Equal(t, 400, c.Stats.Width)
+}
@@ -0,0 +1,140 @@
+package imacon
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/fogleman/gg"
+)
+
+// Format identifies an encoded output format.
+type Format int
+
+const (
+	FormatPNG Format = iota
+	FormatJPEG
+)
+
+const (
+	renderStripHeight = 256      // The number of canvas rows drawn at a time when streaming
+	pngIDATSize       = 64 << 10 // The maximum size of each streamed PNG IDAT chunk
+)
+
+// RenderTo renders the scene and writes it to the writer in the given format.
+//
+// PNG output is drawn and encoded in horizontal strips, so only one strip of the canvas is held in memory at a time.
+// The rows are written unfiltered, trading some compression for bounded memory. JPEG output needs the whole image
+// for encoding, so it is rendered in full and encoded directly without an intermediate copy.
+// Progress callbacks are not invoked when streaming, since every strip draws the whole scene.
+func (e *Engine) RenderTo(scene *Scene, writer io.Writer, format Format) error {
+	switch format {
+	case FormatJPEG:
+		c, err := e.Render(scene)
+		if err != nil {
+			return err
+		}
+		return c.ToJpeg(writer, nil)
+	case FormatPNG:
+		plan, err := e.plan(scene, e.newRenderState())
+		if err != nil {
+			return err
+		}
+		return e.streamPng(writer, scene, plan, e.metadata(scene))
+	default:
+		return fmt.Errorf("imacon: unsupported format %d", format)
+	}
+}
+
+// streamPng draws the planned scene strip by strip and writes each strip's rows to a PNG stream as they are ready.
+func (e *Engine) streamPng(writer io.Writer, scene *Scene, plan *renderPlan, meta metadata) error {
+	width, height := e.scaled(plan.width), e.scaled(plan.height)
+	bw := bufio.NewWriter(writer)
+
+	// header: 8-bit RGBA, no interlacing
+	ihdr := binary.BigEndian.AppendUint32(nil, uint32(width))
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(height))
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	bw.Write(pngSignature)
+	bw.Write(pngChunk("IHDR", ihdr))
+	for _, chunk := range meta.pngChunks() {
+		bw.Write(chunk)
+	}
+
+	idat := &pngIDATWriter{w: bw}
+	zw := zlib.NewWriter(idat)
+	row := make([]byte, 1+width*4) // filter type byte followed by the pixels
+	// a single strip context is reused, the rows past the canvas height in the last strip are discarded
+	ctx := gg.NewContext(width, min(renderStripHeight, height))
+	for y := 0; y < height; y += renderStripHeight {
+		stripH := min(renderStripHeight, height-y)
+		e.draw(ctx, scene, plan, float64(y), e.newRenderState())
+		strip := ctx.Image().(*image.RGBA)
+		if e.cfg.CornerRadius > 0 {
+			roundCorners(strip, y, width, height, e.cfg.CornerRadius*e.outputScale())
+		}
+		for sy := range stripH {
+			writeNRGBARow(row[1:], strip, sy)
+			if _, err := zw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := idat.flush(); err != nil {
+		return err
+	}
+	bw.Write(pngChunk("IEND", nil))
+	return bw.Flush()
+}
+
+// writeNRGBARow converts a row of premultiplied RGBA pixels into the non-premultiplied bytes expected by PNG.
+func writeNRGBARow(dst []byte, src *image.RGBA, y int) {
+	pix := src.Pix[y*src.Stride : y*src.Stride+src.Rect.Dx()*4]
+	for i := 0; i < len(pix); i += 4 {
+		r, g, b, a := pix[i], pix[i+1], pix[i+2], pix[i+3]
+		if a != 0 && a != 0xff {
+			r = uint8(uint32(r) * 0xff / uint32(a))
+			g = uint8(uint32(g) * 0xff / uint32(a))
+			b = uint8(uint32(b) * 0xff / uint32(a))
+		}
+		dst[i], dst[i+1], dst[i+2], dst[i+3] = r, g, b, a
+	}
+}
+
+// pngIDATWriter buffers compressed image data and writes it out as a sequence of IDAT chunks.
+type pngIDATWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (p *pngIDATWriter) Write(data []byte) (int, error) {
+	n := len(data)
+	for len(data) > 0 {
+		take := min(len(data), pngIDATSize-len(p.buf))
+		p.buf = append(p.buf, data[:take]...)
+		data = data[take:]
+		if len(p.buf) == pngIDATSize {
+			if err := p.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *pngIDATWriter) flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	if _, err := p.w.Write(pngChunk("IDAT", p.buf)); err != nil {
+		return err
+	}
+	p.buf = p.buf[:0]
+	return nil
+}
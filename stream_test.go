@@ -0,0 +1,93 @@
+package imacon
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamScene(t testing.TB, count int) *Scene {
+	objects := []Tileable{
+		NewTextBlock("Streaming render of a tall scene, drawn strip by strip.", TextBlockOpts{TextWrap: true}),
+	}
+	images := []string{"assets/samples/sample_1.jpg", "assets/samples/sample_2.jpg", "assets/samples/glasses.png"}
+	for i := range count {
+		objects = append(objects, loadImageBlock(t, images[i%len(images)], fmt.Sprintf("Sample %d", i+1)))
+	}
+	pane := NewPane(objects, 0, 0, 0)
+	pane.Layout = LayoutStack
+	scene := NewScene(pane)
+	scene.Title = "Stream"
+	return scene
+}
+
+func Test_RenderTo(t *testing.T) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 8192, FontSize: 24})
+
+	t.Run("PNG strips match full render", func(t *testing.T) {
+		c, err := eng.Render(newStreamScene(t, 4))
+		require.NoError(t, err)
+		require.Greater(t, c.Height, renderStripHeight, "Scene should span multiple strips")
+
+		var buf bytes.Buffer
+		require.NoError(t, eng.RenderTo(newStreamScene(t, 4), &buf, FormatPNG))
+		assert.Contains(t, buf.String(), "tEXtTitle\x00Stream", "Streamed PNG should include metadata")
+		img, err := png.Decode(&buf)
+		require.NoError(t, err)
+		require.Equal(t, c.Raw.Bounds(), img.Bounds())
+		// image sampling may round differently by a level when the strip offset changes the transform
+		for y := range c.Height {
+			for x := range c.Width {
+				want := color.NRGBAModel.Convert(c.Raw.At(x, y)).(color.NRGBA)
+				got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if !assert.InDelta(t, want.R, got.R, 2, "Pixel (%d, %d) should match the full render", x, y) ||
+					!assert.InDelta(t, want.G, got.G, 2, "Pixel (%d, %d) should match the full render", x, y) ||
+					!assert.InDelta(t, want.B, got.B, 2, "Pixel (%d, %d) should match the full render", x, y) ||
+					!assert.Equal(t, want.A, got.A, "Pixel (%d, %d) should match the full render", x, y) {
+					return
+				}
+			}
+		}
+	})
+
+	t.Run("JPEG", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, eng.RenderTo(newStreamScene(t, 2), &buf, FormatJPEG))
+		_, err := jpeg.Decode(&buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		assert.Error(t, eng.RenderTo(newStreamScene(t, 1), io.Discard, Format(99)))
+	})
+}
+
+// Compare the memory used by a full render and PNG encode against a streamed render, with -benchmem.
+func Benchmark_RenderMemory(b *testing.B) {
+	eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 16384, FontSize: 24})
+
+	scene := newStreamScene(b, 12)
+
+	b.Run("Render+ToPng", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			c, err := eng.Render(scene)
+			require.NoError(b, err)
+			require.NoError(b, c.ToPng(io.Discard))
+		}
+	})
+
+	b.Run("RenderTo", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			require.NoError(b, eng.RenderTo(scene, io.Discard, FormatPNG))
+		}
+	})
+}
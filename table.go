@@ -0,0 +1,152 @@
+package imacon
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	DefaultCellPad     = 6.0 // The default padding inside each table cell
+	DefaultBorderWidth = 1.0 // The default width of the table grid lines
+)
+
+type TableOpts struct {
+	CellPad     float64 // The padding between the cell border and its text
+	BorderWidth float64 // The width of the grid lines
+	TextWrap    bool    // Whether to wrap cell text if the table exceeds the expected width
+}
+
+// Table represents tabular text data drawn as a grid, with each column sized to its widest cell.
+type Table struct {
+	Rows [][]string
+	Opts TableOpts
+}
+
+func NewTable(rows [][]string, opts TableOpts) *Table {
+	if opts.CellPad == 0 {
+		opts.CellPad = DefaultCellPad
+	}
+	if opts.BorderWidth == 0 {
+		opts.BorderWidth = DefaultBorderWidth
+	}
+	return &Table{Rows: rows, Opts: opts}
+}
+
+// colCount returns the number of columns of the widest row.
+func (t *Table) colCount() int {
+	n := 0
+	for _, row := range t.Rows {
+		n = max(n, len(row))
+	}
+	return n
+}
+
+// chrome returns the total width and height taken by cell padding and grid lines.
+func (t *Table) chrome() (float64, float64) {
+	cols, rows := float64(t.colCount()), float64(len(t.Rows))
+	pad, border := t.Opts.CellPad, t.Opts.BorderWidth
+	return cols*pad*2 + (cols+1)*border, rows*pad*2 + (rows+1)*border
+}
+
+// layout measures the column widths and row heights of the table content, excluding padding and borders.
+// When wrapping is enabled and the table exceeds expectedWidth, columns are shrunk proportionally and their cells wrapped.
+func (t *Table) layout(ctx *gg.Context, expectedWidth float64) ([]float64, []float64, [][][]string) {
+	colWidths := make([]float64, t.colCount())
+	for _, row := range t.Rows {
+		for j, cell := range row {
+			w, _ := ctx.MeasureString(cell)
+			colWidths[j] = math.Max(colWidths[j], w)
+		}
+	}
+
+	chromeW, _ := t.chrome()
+	contentW := 0.0
+	for _, w := range colWidths {
+		contentW += w
+	}
+	// empty cells have nothing to shrink, and would divide by zero
+	wrap := t.Opts.TextWrap && expectedWidth > 0 && contentW > 0 && contentW+chromeW > expectedWidth
+	if wrap {
+		ratio := math.Max(expectedWidth-chromeW, 0) / contentW
+		for j := range colWidths {
+			colWidths[j] *= ratio
+		}
+	}
+
+	lineHeight := ctx.FontHeight() * DefaultLineSpacing
+	rowHeights := make([]float64, len(t.Rows))
+	cells := make([][][]string, len(t.Rows))
+	for i, row := range t.Rows {
+		cells[i] = make([][]string, len(row))
+		lineCount := 1
+		for j, cell := range row {
+			if wrap {
+				cells[i][j] = ctx.WordWrap(cell, colWidths[j])
+			} else {
+				cells[i][j] = []string{cell}
+			}
+			lineCount = max(lineCount, len(cells[i][j]))
+		}
+		rowHeights[i] = float64(lineCount) * lineHeight
+	}
+	return colWidths, rowHeights, cells
+}
+
+func (t *Table) Draw(ctx *gg.Context, cw float64, ch float64) {
+	colWidths, rowHeights, cells := t.layout(ctx, cw)
+	pad, border := t.Opts.CellPad, t.Opts.BorderWidth
+	w, h := t.IntrinsicSize(ctx, cw, 0)
+	lineHeight := ctx.FontHeight() * DefaultLineSpacing
+
+	// grid lines, centered on the border strip and snapped to whole pixels
+	ctx.Push()
+	ctx.SetLineWidth(border)
+	vertical := func(x float64) {
+		x, _ = snapStroke(ctx, x, 0, border)
+		ctx.DrawLine(x, 0, x, h)
+	}
+	horizontal := func(y float64) {
+		_, y = snapStroke(ctx, 0, y, border)
+		ctx.DrawLine(0, y, w, y)
+	}
+	x := border / 2
+	vertical(x)
+	for _, colW := range colWidths {
+		x += colW + pad*2 + border
+		vertical(x)
+	}
+	y := border / 2
+	horizontal(y)
+	for _, rowH := range rowHeights {
+		y += rowH + pad*2 + border
+		horizontal(y)
+	}
+	ctx.Stroke()
+	ctx.Pop()
+
+	// cell text
+	y = border + pad
+	for i, row := range cells {
+		x := border + pad
+		for j, lines := range row {
+			for k, line := range lines {
+				drawStringAnchored(ctx, line, x, y+float64(k)*lineHeight, 0, 1, 0)
+			}
+			x += colWidths[j] + pad*2 + border
+		}
+		y += rowHeights[i] + pad*2 + border
+	}
+}
+
+func (t *Table) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	colWidths, rowHeights, _ := t.layout(ctx, expectedWidth)
+	w, h := t.chrome()
+	for _, colW := range colWidths {
+		w += colW
+	}
+	for _, rowH := range rowHeights {
+		h += rowH
+	}
+	return w, h
+}
@@ -0,0 +1,57 @@
+package imacon
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Table(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	rows := [][]string{
+		{"Name", "Category", "Qty"},
+		{"Necklace", "Accessory", "1"},
+	}
+
+	t.Run("Table intrinsic size", func(t *testing.T) {
+		table := NewTable(rows, TableOpts{})
+
+		// each column is sized to its widest cell
+		sumColWidths := 0.0
+		for j := range 3 {
+			colW := 0.0
+			for _, row := range rows {
+				w, _ := ctx.MeasureString(row[j])
+				colW = math.Max(colW, w)
+			}
+			sumColWidths += colW
+		}
+		expectedW := sumColWidths + 3*2*DefaultCellPad + 4*DefaultBorderWidth
+		expectedH := 2*ctx.FontHeight()*DefaultLineSpacing + 2*2*DefaultCellPad + 3*DefaultBorderWidth
+
+		w, h := table.IntrinsicSize(ctx, 0, 0)
+		assert.InDelta(t, expectedW, w, 1e-9, "Width should be sum of column widths plus padding and borders")
+		assert.InDelta(t, expectedH, h, 1e-9, "Height should be sum of row heights plus padding and borders")
+	})
+
+	t.Run("Table wraps cells", func(t *testing.T) {
+		table := NewTable([][]string{
+			{"Item", "Description"},
+			{"Necklace", "A silver necklace with a small pendant"},
+		}, TableOpts{TextWrap: true})
+		w0, h0 := table.IntrinsicSize(ctx, 0, 0)
+		w, h := table.IntrinsicSize(ctx, w0/2, 0)
+		assert.LessOrEqual(t, w, w0/2+1e-9, "Width should fit within the expected width when wrapped")
+		assert.Greater(t, h, h0, "Height should grow when cells are wrapped")
+	})
+
+	t.Run("Empty table narrower than its chrome", func(t *testing.T) {
+		table := NewTable([][]string{{"", ""}, {"", ""}}, TableOpts{TextWrap: true})
+		colWidths, _, _ := table.layout(ctx, 1)
+		assert.Equal(t, []float64{0, 0}, colWidths, "Empty columns should not be shrunk to NaN")
+		w, h := table.IntrinsicSize(ctx, 1, 0)
+		assert.False(t, math.IsNaN(w) || math.IsNaN(h))
+	})
+}
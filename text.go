@@ -0,0 +1,731 @@
+package imacon
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+const (
+	DefaultShadowOffset = 1.0  // The default offset of a text shadow
+	DefaultOutlineWidth = 1.0  // The default width of a text outline
+	DefaultTabSize      = 4    // The default number of space advances between the tab stops of text without TextBlockOpts.Tabs
+	autoShadowAlpha     = 0x99 // The opacity of the contrasting shadow drawn by TextBlockOpts.AutoShadow
+)
+
+// The suffix appended to the last line of text cut off by TextBlockOpts.MaxLines.
+const ellipsis = "…"
+
+// VAlign defines the vertical alignment of text within a box taller than the text.
+type VAlign int
+
+const (
+	VAlignTop    VAlign = iota // Align the text to the top of the box
+	VAlignMiddle               // Center the text vertically in the box
+	VAlignBottom               // Align the text to the bottom of the box
+)
+
+// HAlign defines the horizontal alignment of each line of text within the width of its box.
+type HAlign int
+
+const (
+	HAlignLeft   HAlign = iota // Align the lines to the left edge of the box
+	HAlignCenter               // Center the lines horizontally in the box
+	HAlignRight                // Align the lines to the right edge of the box
+	// Align the lines to the edge the text starts from: the right edge for right-to-left text, e.g. Arabic or Hebrew,
+	// and the left edge otherwise. The direction is detected from the first letter of the text.
+	HAlignStart
+)
+
+type TextBlockOpts struct {
+	TextWrap       bool      // Whether to wrap text if it exceeds the pane width
+	BreakLongWords bool      // Whether to break words wider than the wrap width between characters, e.g. long URLs, instead of letting them overflow
+	MaxLines       int       // The maximum number of wrapped lines. Text past the limit is cut off and the last line ends with an ellipsis. Zero means unlimited.
+	LetterSpacing  float64   // The extra space added between characters, in pixels
+	FontSize       float64   // The font size of this block, clamped to [MinFontSize, MaxFontSize]. Zero uses the engine font size.
+	AutoFit        bool      // Whether to pick the largest font size that fits the text within a box of given width and height
+	MinFontSize    float64   // The smallest font size allowed for FontSize and AutoFit. Zero defaults to DefaultMinFontSize.
+	MaxFontSize    float64   // The largest font size allowed for FontSize and AutoFit. Zero defaults to DefaultMaxFontSize.
+	Tabs           []float64 // The increasing x positions of the tab stops within the block. When set, each tab advances to the next stop past the text before it.
+	TabSize        int       // The number of space advances between the evenly spaced tab stops used when Tabs is empty. Zero defaults to DefaultTabSize.
+	Rotation       int       // The clockwise rotation of the text in degrees, one of 0, 90, 180 or 270. Rotated text is never wrapped.
+	VAlign         VAlign    // The vertical alignment of the text when drawn in a box taller than the text
+	HAlign         HAlign    // The horizontal alignment of the lines within the box width, or the widest line when the width is zero
+	// Whether to keep the text pre-formatted, e.g. for code or addresses: every line break starts a new line, including
+	// blank lines, and the indentation of each line is kept. Overly long lines are still wrapped when TextWrap is set.
+	PreserveNewlines bool
+	// Whether to render minimal inline markdown: **bold** and *italic* spans in the bold and italic fonts of the engine,
+	// synthesized from the regular font when the engine has none, and `code` spans on a faint box. Tabs are not expanded in markdown text.
+	Markdown bool
+	// The color of the text. Nil uses the current foreground color.
+	Color color.Color
+	// The color of a drop shadow drawn behind the text, e.g. for legibility over images. Nil draws no shadow.
+	ShadowColor color.Color
+	// The offset of the shadow to the bottom right of the text, in pixels. Zero defaults to DefaultShadowOffset.
+	ShadowOffset float64
+	// Whether to draw a translucent black or white shadow, whichever contrasts with the text color, when ShadowColor is nil.
+	// It keeps light and dark text legible over varying backgrounds.
+	AutoShadow bool
+	// The color of an outline drawn around the glyphs. Nil draws no outline.
+	OutlineColor color.Color
+	// The width of the outline, in pixels. Zero defaults to DefaultOutlineWidth.
+	// The shadow and outline are not included in the measured size, they spill into the surrounding padding instead,
+	// which panes raise to clear them, see Bleed.
+	OutlineWidth float64
+	// The color of a single box filled behind the whole block, e.g. for speech bubbles. Nil draws no box.
+	BoxColor color.Color
+	// The space between the text and the edges of its box, added on every side of the measured size.
+	BoxPadding float64
+	// The corner radius of the box. Zero draws square corners.
+	BoxRadius float64
+}
+
+type TextBlock struct {
+	// Representation of a plain-text.
+	Text string
+	Opts TextBlockOpts
+}
+
+func NewTextBlock(text string, opts TextBlockOpts) *TextBlock {
+	return &TextBlock{Text: text, Opts: opts}
+}
+
+func (t *TextBlock) Draw(ctx *gg.Context, cw float64, ch float64) {
+	if t.Opts.Color != nil {
+		ctx.Push()
+		defer ctx.Pop()
+		ctx.SetColor(t.Opts.Color)
+	}
+	if offset := t.alignOffset(ctx, cw, ch); offset > 0 {
+		ctx.Push()
+		defer ctx.Pop()
+		ctx.Translate(0, offset)
+	}
+	if pad := t.Opts.BoxPadding; t.Opts.BoxColor != nil || pad > 0 {
+		ctx.Push()
+		defer ctx.Pop()
+		t.drawBox(ctx, cw, ch)
+		ctx.Translate(pad, pad)
+		cw, ch = t.unpadded(cw), t.unpadded(ch)
+	}
+	cw, ch = t.unrotated(cw, ch)
+	if size, ok := t.fontSize(ctx, cw, ch); ok {
+		t.withFontSize(ctx, size, func() {
+			t.drawRotated(ctx, cw)
+		})
+		return
+	}
+	t.drawRotated(ctx, cw)
+}
+
+// alignOffset returns how far down the text is moved to follow the vertical alignment within a box of the given size.
+func (t *TextBlock) alignOffset(ctx *gg.Context, cw float64, ch float64) float64 {
+	if t.Opts.VAlign == VAlignTop || ch == 0 {
+		return 0
+	}
+	_, h := t.IntrinsicSize(ctx, cw, ch)
+	if t.Opts.VAlign == VAlignMiddle {
+		return (ch - h) / 2
+	}
+	return ch - h
+}
+
+// drawBox fills the box behind the text, spanning the box width or the measured width when it is zero.
+func (t *TextBlock) drawBox(ctx *gg.Context, cw float64, ch float64) {
+	if t.Opts.BoxColor == nil {
+		return
+	}
+	w, h := t.IntrinsicSize(ctx, cw, ch)
+	if cw > 0 {
+		w = cw
+	}
+	ctx.Push()
+	ctx.SetColor(t.Opts.BoxColor)
+	if t.Opts.BoxRadius > 0 {
+		ctx.DrawRoundedRectangle(0, 0, w, h, t.Opts.BoxRadius)
+	} else {
+		ctx.DrawRectangle(0, 0, w, h)
+	}
+	ctx.Fill()
+	ctx.Pop()
+}
+
+// unpadded returns the size left for the text within a box dimension after the box padding on both sides.
+// Zero stays zero, so that unbounded dimensions remain unbounded.
+func (t *TextBlock) unpadded(v float64) float64 {
+	if v == 0 || t.Opts.BoxPadding <= 0 {
+		return v
+	}
+	return math.Max(v-2*t.Opts.BoxPadding, 0)
+}
+
+// drawRotated draws the text rotated about its box, so that the rotated box still has its top-left corner at the origin.
+func (t *TextBlock) drawRotated(ctx *gg.Context, cw float64) {
+	if t.rotation() == 0 {
+		t.draw(ctx, cw)
+		return
+	}
+	w, h := t.measure(ctx, 0)
+	ctx.Push()
+	switch t.rotation() {
+	case 90:
+		ctx.Translate(h, 0)
+	case 180:
+		ctx.Translate(w, h)
+	case 270:
+		ctx.Translate(0, w)
+	}
+	ctx.Rotate(gg.Radians(float64(t.rotation())))
+	t.draw(ctx, 0)
+	ctx.Pop()
+}
+
+func (t *TextBlock) draw(ctx *gg.Context, cw float64) {
+	if !t.wraps() && t.Opts.PreserveNewlines {
+		lineHeight := lineHeight(ctx)
+		for i, line := range strings.Split(t.text(), "\n") {
+			t.drawLine(ctx, line, t.alignX(ctx, line, cw), float64(i)*lineHeight)
+		}
+	} else if !t.wraps() {
+		t.drawLine(ctx, t.text(), t.alignX(ctx, t.text(), cw), 0)
+	} else {
+		lineHeight := lineHeight(ctx)
+		for i, line := range t.wrap(ctx, cw) {
+			t.drawLine(ctx, line, t.alignX(ctx, line, cw), float64(i)*lineHeight)
+		}
+	}
+}
+
+// alignX returns the x offset of a line following the horizontal alignment within the box width.
+func (t *TextBlock) alignX(ctx *gg.Context, line string, cw float64) float64 {
+	align := t.hAlign()
+	if align == HAlignLeft {
+		return 0
+	}
+	if cw == 0 {
+		cw, _ = t.measure(ctx, 0)
+	}
+	offset := cw - t.measureLine(ctx, line)
+	if align == HAlignCenter {
+		offset /= 2
+	}
+	return math.Max(offset, 0)
+}
+
+// hAlign returns the horizontal alignment of the lines, with HAlignStart resolved to the direction of the text.
+func (t *TextBlock) hAlign() HAlign {
+	return t.Opts.HAlign.resolve(t.Text)
+}
+
+// resolve returns the alignment for the text, HAlignStart resolved to HAlignRight for right-to-left text and HAlignLeft otherwise.
+func (a HAlign) resolve(text string) HAlign {
+	if a != HAlignStart {
+		return a
+	}
+	if isRightToLeft(text) {
+		return HAlignRight
+	}
+	return HAlignLeft
+}
+
+// isRightToLeft reports whether the first letter of the text is of a script written from right to left.
+func isRightToLeft(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			return unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko)
+		}
+	}
+	return false
+}
+
+func (t *TextBlock) IntrinsicSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	w, h := t.textSize(ctx, t.unpadded(expectedWidth), t.unpadded(expectedHeight))
+	if pad := t.Opts.BoxPadding; pad > 0 {
+		return w + 2*pad, h + 2*pad
+	}
+	return w, h
+}
+
+// Baseline returns the distance from the top of the block to the baseline of its first line, including the box padding,
+// for lining up text blocks side by side. Rotated text has no horizontal baseline and reports its top.
+func (t *TextBlock) Baseline(ctx *gg.Context, expectedWidth float64) float64 {
+	if t.rotation() != 0 {
+		return 0
+	}
+	baseline, _ := lineMetrics(ctx)
+	if size, ok := t.fontSize(ctx, t.unpadded(expectedWidth), 0); ok {
+		t.withFontSize(ctx, size, func() {
+			baseline, _ = lineMetrics(ctx)
+		})
+	}
+	return baseline + math.Max(t.Opts.BoxPadding, 0)
+}
+
+// textSize returns the size of the text within a box of the expected size, without the box padding.
+func (t *TextBlock) textSize(ctx *gg.Context, expectedWidth float64, expectedHeight float64) (float64, float64) {
+	expectedWidth, expectedHeight = t.unrotated(expectedWidth, expectedHeight)
+	var w, h float64
+	if size, ok := t.fontSize(ctx, expectedWidth, expectedHeight); ok {
+		t.withFontSize(ctx, size, func() {
+			w, h = t.measure(ctx, expectedWidth)
+		})
+	} else {
+		w, h = t.measure(ctx, expectedWidth)
+	}
+	return t.unrotated(w, h)
+}
+
+// rotation returns the rotation of the text normalized into [0, 360), or zero if it isn't a quarter turn.
+func (t *TextBlock) rotation() int {
+	rotation := (t.Opts.Rotation%360 + 360) % 360
+	if rotation%90 != 0 {
+		return 0
+	}
+	return rotation
+}
+
+// unrotated swaps the width and height for text turned by a quarter, converting between the box on the canvas and the box of the text.
+func (t *TextBlock) unrotated(w float64, h float64) (float64, float64) {
+	if t.rotation()%180 == 90 {
+		return h, w
+	}
+	return w, h
+}
+
+// lineMetricsCache holds the ascent and descent of the embedded font by face height.
+var lineMetricsCache sync.Map
+
+// lineMetrics returns the ascent and descent of the current font face, the extent of a line above and below its baseline.
+// gg can't read the face back from the context, so the metrics are those of the embedded font at the height of the face,
+// which all faces set by the engine are.
+func lineMetrics(ctx *gg.Context) (float64, float64) {
+	size := ctx.FontHeight()
+	if metrics, ok := lineMetricsCache.Load(size); ok {
+		m := metrics.([2]float64)
+		return m[0], m[1]
+	}
+	face, err := newFontFace(size)
+	if err != nil {
+		return size, 0
+	}
+	m := face.Metrics()
+	metrics := [2]float64{float64(m.Ascent) / 64, float64(m.Descent) / 64}
+	lineMetricsCache.Store(size, metrics)
+	return metrics[0], metrics[1]
+}
+
+// lineHeight returns the advance between the baselines of consecutive lines, the ascent plus descent of the face
+// times DefaultLineSpacing.
+func lineHeight(ctx *gg.Context) float64 {
+	ascent, descent := lineMetrics(ctx)
+	return (ascent + descent) * DefaultLineSpacing
+}
+
+// textHeight returns the drawn extent of the given number of lines, from the top of the first to the bottom of the
+// last, without the spacing below the last line.
+func textHeight(ctx *gg.Context, lines int) float64 {
+	if lines == 0 {
+		return 0
+	}
+	ascent, descent := lineMetrics(ctx)
+	return float64(lines-1)*lineHeight(ctx) + ascent + descent
+}
+
+// text returns the text to lay out, with the markers of markdown text repeated for every word so that each line can be
+// measured and drawn on its own.
+func (t *TextBlock) text() string {
+	if t.Opts.Markdown {
+		return markdownWords(t.Text)
+	}
+	return t.Text
+}
+
+// wraps reports whether the text is wrapped to the width of its box.
+func (t *TextBlock) wraps() bool {
+	return t.Opts.TextWrap && t.rotation() == 0
+}
+
+// measure returns the size of the unrotated text in the current font face, wrapped to expectedWidth when non-zero
+// and the text isn't rotated.
+func (t *TextBlock) measure(ctx *gg.Context, expectedWidth float64) (float64, float64) {
+	if expectedWidth == 0 || t.rotation() != 0 {
+		lines := strings.Split(t.text(), "\n")
+		maxWidth := 0.0
+		for _, line := range lines {
+			maxWidth = max(maxWidth, t.measureLine(ctx, line))
+		}
+		return maxWidth, textHeight(ctx, len(lines))
+	} else {
+		lines := t.wrap(ctx, expectedWidth)
+		maxWidth := 0.0
+		for _, line := range lines {
+			w := t.measureLine(ctx, line)
+			if w > maxWidth {
+				maxWidth = w
+			}
+		}
+		return maxWidth, textHeight(ctx, len(lines))
+	}
+}
+
+// fontSize returns the font size the block draws with in a box of the given size, if it overrides the context's face.
+func (t *TextBlock) fontSize(ctx *gg.Context, width float64, height float64) (float64, bool) {
+	if t.Opts.AutoFit && width > 0 && height > 0 {
+		return t.fitFontSize(ctx, width, height), true
+	}
+	if t.Opts.FontSize != 0 {
+		return clampFontSize(t.Opts.FontSize, t.Opts.MinFontSize, t.Opts.MaxFontSize), true
+	}
+	return 0, false
+}
+
+// clampFontSize clamps the font size into [minSize, maxSize], where zero bounds default to DefaultMinFontSize and DefaultMaxFontSize.
+func clampFontSize(size float64, minSize float64, maxSize float64) float64 {
+	if minSize == 0 {
+		minSize = DefaultMinFontSize
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxFontSize
+	}
+	return math.Max(minSize, math.Min(size, maxSize))
+}
+
+// fitFontSize returns the largest whole font size within the configured range at which the text fits in the box.
+// If the text doesn't fit even at the smallest size, the smallest size is returned.
+func (t *TextBlock) fitFontSize(ctx *gg.Context, width float64, height float64) float64 {
+	minSize, maxSize := t.Opts.MinFontSize, t.Opts.MaxFontSize
+	if minSize == 0 {
+		minSize = DefaultMinFontSize
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxFontSize
+	}
+
+	best := minSize
+	lo, hi := int(math.Ceil(minSize)), int(math.Floor(maxSize))
+	for lo <= hi {
+		size := (lo + hi) / 2
+		fits := false
+		t.withFontSize(ctx, float64(size), func() {
+			w, h := t.measure(ctx, width)
+			fits = w <= width && h <= height
+		})
+		if fits {
+			best = float64(size)
+			lo = size + 1
+		} else {
+			hi = size - 1
+		}
+	}
+	return best
+}
+
+// withFontSize calls fn with the context's font face temporarily set to the given size.
+// The current face is kept if the font can't be loaded.
+func (t *TextBlock) withFontSize(ctx *gg.Context, size float64, fn func()) {
+	ctx.Push()
+	defer ctx.Pop()
+	if face, err := fontFaceOf(ctx, size); err == nil {
+		ctx.SetFontFace(face)
+	}
+	fn()
+}
+
+// measureLine returns the width of a single line of text, including the letter spacing and tab stops.
+func (t *TextBlock) measureLine(ctx *gg.Context, line string) float64 {
+	if t.Opts.Markdown {
+		return t.measureMarkdown(ctx, line)
+	}
+	if strings.ContainsRune(line, '\t') {
+		segments, offsets := t.tabSegments(ctx, line)
+		return offsets[len(offsets)-1] + t.measureSegment(ctx, segments[len(segments)-1])
+	}
+	return t.measureSegment(ctx, line)
+}
+
+// tabSegments splits the line on tabs and returns the segments with the x offset each one starts at.
+// Without explicit stops, a tab advances to the next multiple of TabSize space advances, so that tabbed columns line up
+// in monospace text. A tab past the last explicit stop advances by the width of a space.
+func (t *TextBlock) tabSegments(ctx *gg.Context, line string) ([]string, []float64) {
+	segments := strings.Split(line, "\t")
+	offsets := make([]float64, len(segments))
+	space := t.measureSegment(ctx, " ")
+	for i := 1; i < len(segments); i++ {
+		end := offsets[i-1] + t.measureSegment(ctx, segments[i-1])
+		if len(t.Opts.Tabs) == 0 {
+			step := float64(t.tabSize()) * space
+			offsets[i] = (math.Floor(end/step) + 1) * step
+			continue
+		}
+		offsets[i] = end + space
+		for _, stop := range t.Opts.Tabs {
+			if stop > end {
+				offsets[i] = stop
+				break
+			}
+		}
+	}
+	return segments, offsets
+}
+
+// tabSize returns the number of space advances between the implicit tab stops, falling back to DefaultTabSize.
+func (t *TextBlock) tabSize() int {
+	if t.Opts.TabSize > 0 {
+		return t.Opts.TabSize
+	}
+	return DefaultTabSize
+}
+
+// measureSegment returns the width of a run of text without tabs, including the letter spacing.
+func (t *TextBlock) measureSegment(ctx *gg.Context, line string) float64 {
+	w, _ := ctx.MeasureString(line)
+	if n := utf8.RuneCountInString(line); n > 1 {
+		w += t.Opts.LetterSpacing * float64(n-1)
+	}
+	return w
+}
+
+// drawLine draws a single line of text with its top-left corner at (x, y), along with its shadow and outline.
+func (t *TextBlock) drawLine(ctx *gg.Context, line string, x float64, y float64) {
+	if t.Opts.Markdown {
+		t.drawCodeSpans(ctx, line, x, y)
+	}
+	if shadow := t.shadowColor(ctx); shadow != nil {
+		offset := t.Opts.ShadowOffset
+		if offset == 0 {
+			offset = DefaultShadowOffset
+		}
+		ctx.Push()
+		ctx.SetColor(shadow)
+		t.drawGlyphs(ctx, line, x+offset, y+offset)
+		ctx.Pop()
+	}
+	if t.Opts.OutlineColor != nil {
+		width := t.Opts.OutlineWidth
+		if width == 0 {
+			width = DefaultOutlineWidth
+		}
+		// gg can't stroke glyphs, so the outline is built from copies of the text offset around a circle
+		steps := max(8, int(math.Ceil(2*math.Pi*width)))
+		ctx.Push()
+		ctx.SetColor(t.Opts.OutlineColor)
+		for i := range steps {
+			angle := 2 * math.Pi * float64(i) / float64(steps)
+			t.drawGlyphs(ctx, line, x+width*math.Cos(angle), y+width*math.Sin(angle))
+		}
+		ctx.Pop()
+	}
+	t.drawGlyphs(ctx, line, x, y)
+}
+
+// Bleed returns how far the shadow and outline of the text may spill out of its measured box, so that panes keep
+// enough padding between the block and its neighbors. Objects of panes may implement Bleed for their own decorations.
+func (t *TextBlock) Bleed() float64 {
+	bleed := 0.0
+	if t.Opts.ShadowColor != nil || t.Opts.AutoShadow {
+		// a negative offset casts the shadow up and to the left, which bleeds just as far
+		bleed = math.Abs(t.Opts.ShadowOffset)
+		if bleed == 0 {
+			bleed = DefaultShadowOffset
+		}
+	}
+	if t.Opts.OutlineColor != nil {
+		width := t.Opts.OutlineWidth
+		if width == 0 {
+			width = DefaultOutlineWidth
+		}
+		bleed = math.Max(bleed, width)
+	}
+	return bleed
+}
+
+// withColor returns the block drawn in the given color unless it has a color of its own, e.g. for the caption color of
+// image blocks, so that AutoShadow contrasts with the color the text is actually drawn in. Nil returns the block as is.
+func (t *TextBlock) withColor(c color.Color) *TextBlock {
+	if c == nil || t.Opts.Color != nil {
+		return t
+	}
+	colored := *t
+	colored.Opts.Color = c
+	return &colored
+}
+
+// shadowColor returns the color of the text shadow, picking one that contrasts with the text color for AutoShadow.
+func (t *TextBlock) shadowColor(ctx *gg.Context) color.Color {
+	if t.Opts.ShadowColor != nil || !t.Opts.AutoShadow {
+		return t.Opts.ShadowColor
+	}
+	text := t.Opts.Color
+	if text == nil {
+		text = stateOf(ctx).fgColor
+	}
+	shadow := color.NRGBAModel.Convert(contrastColor(text)).(color.NRGBA)
+	shadow.A = autoShadowAlpha
+	return shadow
+}
+
+// drawGlyphs draws a single line of text in the current color with its top-left corner at (x, y).
+func (t *TextBlock) drawGlyphs(ctx *gg.Context, line string, x float64, y float64) {
+	if t.Opts.Markdown {
+		t.drawMarkdown(ctx, line, x, y)
+		return
+	}
+	if strings.ContainsRune(line, '\t') {
+		segments, offsets := t.tabSegments(ctx, line)
+		for i, segment := range segments {
+			t.drawSegment(ctx, segment, x+offsets[i], y, 0)
+		}
+		return
+	}
+	t.drawSegment(ctx, line, x, y, 0)
+}
+
+// drawStringAnchored draws the text like ctx.DrawStringAnchored in the current face, which has the given style. Under
+// the output scale of the render, the glyphs are drawn with a face of the scaled size instead, rather than being
+// rasterized at the logical size and resampled by the transform, so that text stays sharp at 2x and 3x.
+func drawStringAnchored(ctx *gg.Context, s string, x float64, y float64, ax float64, ay float64, style fontStyle) {
+	scale := stateOf(ctx).outputScale
+	if scale <= 0 || scale == 1 {
+		ctx.DrawStringAnchored(s, x, y, ax, ay)
+		return
+	}
+	var face font.Face
+	if style != 0 {
+		face, _ = styledFaceOf(ctx, ctx.FontHeight()*scale, style)
+	} else {
+		face, _ = fontFaceOf(ctx, ctx.FontHeight()*scale)
+	}
+	if face == nil {
+		ctx.DrawStringAnchored(s, x, y, ax, ay)
+		return
+	}
+	// the anchor is measured with the logical face, like the layout
+	w, h := ctx.MeasureString(s)
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.Scale(1/scale, 1/scale)
+	ctx.SetFontFace(face)
+	ctx.DrawString(s, (x-ax*w)*scale, (y+ay*h)*scale)
+}
+
+// drawSegment draws a run of text without tabs in the current color with its top-left corner at (x, y), in the
+// current face, which has the given style.
+func (t *TextBlock) drawSegment(ctx *gg.Context, line string, x float64, y float64, style fontStyle) {
+	ascent, _ := lineMetrics(ctx)
+	if t.Opts.LetterSpacing == 0 {
+		drawStringAnchored(ctx, line, x, y+ascent, 0, 0, style)
+		return
+	}
+	// gg has no tracking support, so the runes are drawn one at a time
+	for _, r := range line {
+		s := string(r)
+		drawStringAnchored(ctx, s, x, y+ascent, 0, 0, style)
+		w, _ := ctx.MeasureString(s)
+		x += w + t.Opts.LetterSpacing
+	}
+}
+
+// wrap splits the text into lines that fit within width. It follows the word wrapping of gg.Context.WordWrap,
+// but measures the lines with measureLine so that letter spacing is accounted for.
+func (t *TextBlock) wrap(ctx *gg.Context, width float64) []string {
+	var result []string
+	var indented []bool // Whether each line starts a line of the text, keeping its indentation when preserving newlines
+	for _, line := range strings.Split(t.text(), "\n") {
+		start := len(result)
+		fields := splitOnSpace(line)
+		if len(fields)%2 == 1 {
+			fields = append(fields, "")
+		}
+		x := ""
+		for i := 0; i < len(fields); i += 2 {
+			if t.measureLine(ctx, x+fields[i]) > width {
+				if t.Opts.BreakLongWords && t.measureLine(ctx, fields[i]) > width {
+					if x != "" {
+						result = append(result, x)
+					}
+					chunks := t.breakWord(ctx, fields[i], width)
+					result = append(result, chunks[:len(chunks)-1]...)
+					x = chunks[len(chunks)-1] + fields[i+1]
+					continue
+				}
+				if x == "" {
+					result = append(result, fields[i])
+					continue
+				}
+				result = append(result, x)
+				x = ""
+			}
+			x += fields[i] + fields[i+1]
+		}
+		if x != "" {
+			result = append(result, x)
+		}
+		if t.Opts.PreserveNewlines && len(result) == start {
+			result = append(result, "")
+		}
+		for i := start; i < len(result); i++ {
+			indented = append(indented, i == start && t.Opts.PreserveNewlines)
+		}
+	}
+	for i, line := range result {
+		if indented[i] {
+			result[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+		} else {
+			result[i] = strings.TrimSpace(line)
+		}
+	}
+	if t.Opts.MaxLines > 0 && len(result) > t.Opts.MaxLines {
+		result = result[:t.Opts.MaxLines]
+		result[len(result)-1] = t.ellipsize(ctx, result[len(result)-1], width)
+	}
+	return result
+}
+
+// ellipsize drops characters from the end of a truncated line until it fits within width followed by an ellipsis.
+func (t *TextBlock) ellipsize(ctx *gg.Context, line string, width float64) string {
+	runes := []rune(line)
+	for len(runes) > 0 && t.measureLine(ctx, string(runes)+ellipsis) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + ellipsis
+}
+
+// breakWord splits a word into chunks of characters that each fit within width, keeping at least one character per chunk.
+func (t *TextBlock) breakWord(ctx *gg.Context, word string, width float64) []string {
+	if t.Opts.Markdown {
+		return t.breakMarkdownWord(ctx, word, width)
+	}
+	var chunks []string
+	chunk := ""
+	for _, r := range word {
+		if chunk != "" && t.measureLine(ctx, chunk+string(r)) > width {
+			chunks = append(chunks, chunk)
+			chunk = ""
+		}
+		chunk += string(r)
+	}
+	return append(chunks, chunk)
+}
+
+// splitOnSpace splits the string into alternating runs of non-space and space characters, where non-breaking spaces
+// count as non-space.
+func splitOnSpace(s string) []string {
+	var result []string
+	pi := 0
+	ps := false
+	for i, c := range s {
+		isSpace := breaksLine(c)
+		if isSpace != ps && i > 0 {
+			result = append(result, s[pi:i])
+			pi = i
+		}
+		ps = isSpace
+	}
+	return append(result, s[pi:])
+}
@@ -0,0 +1,397 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TextBlockLetterSpacing(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	const text = "Character Sheet"
+
+	plain := NewTextBlock(text, TextBlockOpts{})
+	spaced := NewTextBlock(text, TextBlockOpts{LetterSpacing: 4})
+	w, h := plain.IntrinsicSize(ctx, 0, 0)
+	sw, sh := spaced.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, w+4*float64(len(text)-1), sw, "Letter spacing should add to the width between each character")
+	assert.Equal(t, h, sh, "Letter spacing should not change the height")
+
+	t.Run("Wrapping accounts for letter spacing", func(t *testing.T) {
+		wrapped := NewTextBlock("Lorem ipsum dolor sit amet, consectetur adipiscing elit.", TextBlockOpts{TextWrap: true, LetterSpacing: 6})
+		lines := wrapped.wrap(ctx, 200)
+		assert.Greater(t, len(lines), 1)
+		for _, line := range lines {
+			assert.LessOrEqual(t, wrapped.measureLine(ctx, line), 200.0, "Wrapped line %q should fit within the width", line)
+		}
+		ww, _ := wrapped.IntrinsicSize(ctx, 200, 0)
+		assert.LessOrEqual(t, ww, 200.0)
+	})
+}
+
+func Test_TextBlockAutoFit(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	opts := TextBlockOpts{TextWrap: true, AutoFit: true}
+	short := NewTextBlock("Sold", opts)
+	long := NewTextBlock("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.", opts)
+
+	shortSize := short.fitFontSize(ctx, 300, 100)
+	longSize := long.fitFontSize(ctx, 300, 100)
+	assert.Less(t, longSize, shortSize, "Longer text should get a smaller font size")
+	assert.Equal(t, DefaultMaxFontSize, shortSize, "Short text should be capped at the max font size")
+	assert.GreaterOrEqual(t, longSize, DefaultMinFontSize)
+
+	w, h := long.IntrinsicSize(ctx, 300, 100)
+	assert.LessOrEqual(t, w, 300.0, "Fitted text should fit within the box width")
+	assert.LessOrEqual(t, h, 100.0, "Fitted text should fit within the box height")
+
+	t.Run("Custom font size range", func(t *testing.T) {
+		capped := NewTextBlock("Sold", TextBlockOpts{AutoFit: true, MinFontSize: 8, MaxFontSize: 20})
+		assert.Equal(t, 20.0, capped.fitFontSize(ctx, 300, 100))
+	})
+}
+
+func Test_FontSizeClamp(t *testing.T) {
+	t.Run("Configured font size", func(t *testing.T) {
+		assert.Equal(t, DefaultMaxFontSize, New(Config{FontSize: 100}).fontSize(), "Large font size should be clamped to the max font size")
+		assert.Equal(t, DefaultMinFontSize, New(Config{FontSize: 4}).fontSize(), "Small font size should be clamped to the min font size")
+		assert.Equal(t, 100.0, New(Config{FontSize: 100, MaxFontSize: 120}).fontSize(), "Max font size should be overridable")
+
+		scene := NewScene(NewPane([]Tileable{NewTextBlock("Hello, World!", TextBlockOpts{})}, 0, 0, 0))
+		_, clampedH, _, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: 100}).Measure(scene)
+		assert.NoError(t, err)
+		_, maxH, _, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, FontSize: DefaultMaxFontSize}).Measure(scene)
+		assert.NoError(t, err)
+		assert.Equal(t, maxH, clampedH, "Rendering should use the clamped font size")
+	})
+
+	t.Run("Block font size", func(t *testing.T) {
+		ctx := gg.NewContext(1024, 1024)
+		_, h := NewTextBlock("Hello", TextBlockOpts{FontSize: 100}).IntrinsicSize(ctx, 0, 0)
+		_, maxH := NewTextBlock("Hello", TextBlockOpts{FontSize: DefaultMaxFontSize}).IntrinsicSize(ctx, 0, 0)
+		assert.Equal(t, maxH, h, "Block font size should be clamped to the max font size")
+	})
+}
+
+func Test_TextBlockRotation(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	w, h := NewTextBlock("Spine", TextBlockOpts{}).IntrinsicSize(ctx, 0, 0)
+
+	for _, rotation := range []int{90, 270} {
+		rw, rh := NewTextBlock("Spine", TextBlockOpts{Rotation: rotation}).IntrinsicSize(ctx, 0, 0)
+		assert.Equal(t, h, rw, "Text rotated by %d degrees should report swapped dimensions", rotation)
+		assert.Equal(t, w, rh, "Text rotated by %d degrees should report swapped dimensions", rotation)
+	}
+	rw, rh := NewTextBlock("Spine", TextBlockOpts{Rotation: 180}).IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, w, rw)
+	assert.Equal(t, h, rh)
+
+	t.Run("Rotated text is not wrapped", func(t *testing.T) {
+		const text = "Lorem ipsum dolor sit amet"
+		w, h := NewTextBlock(text, TextBlockOpts{}).IntrinsicSize(ctx, 0, 0)
+		rw, rh := NewTextBlock(text, TextBlockOpts{TextWrap: true, Rotation: 90}).IntrinsicSize(ctx, 50, 0)
+		assert.Equal(t, h, rw)
+		assert.Equal(t, w, rh)
+	})
+
+	t.Run("Rotated text is drawn within its box", func(t *testing.T) {
+		block := NewTextBlock("Spine", TextBlockOpts{Rotation: 90})
+		rw, rh := block.IntrinsicSize(ctx, 0, 0)
+		ctx := gg.NewContext(int(rw)*2, int(rh)*2)
+		ctx.SetColor(color.Black)
+		block.Draw(ctx, rw, rh)
+		img := ctx.Image()
+		inside, outside := 0, 0
+		for y := range img.Bounds().Dy() {
+			for x := range img.Bounds().Dx() {
+				if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+					if float64(x) < rw+1 && float64(y) < rh+1 {
+						inside++
+					} else {
+						outside++
+					}
+				}
+			}
+		}
+		assert.Greater(t, inside, 0, "Rotated text should be drawn")
+		assert.Equal(t, 0, outside, "Rotated text should stay within its reported size")
+	})
+}
+
+func Test_TextBlockOutline(t *testing.T) {
+	// darkPixels counts the pixels darker than mid gray in white text drawn over a white background
+	darkPixels := func(opts TextBlockOpts) int {
+		ctx := gg.NewContext(200, 50)
+		ctx.SetColor(color.White)
+		ctx.Clear()
+		NewTextBlock("Outline", opts).Draw(ctx, 200, 50)
+		count := 0
+		img := ctx.Image()
+		for y := range img.Bounds().Dy() {
+			for x := range img.Bounds().Dx() {
+				if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	assert.Equal(t, 0, darkPixels(TextBlockOpts{}), "White text on white should have no dark pixels")
+	outlined := darkPixels(TextBlockOpts{OutlineColor: color.Black, OutlineWidth: 2})
+	assert.Greater(t, outlined, 0, "Outline should surround the glyphs with darker pixels")
+	assert.Greater(t, darkPixels(TextBlockOpts{ShadowColor: color.Black}), 0, "Shadow should draw darker pixels next to the glyphs")
+	assert.Greater(t, outlined, darkPixels(TextBlockOpts{OutlineColor: color.Black, OutlineWidth: 1}), "Wider outlines should cover more pixels")
+}
+
+func Test_TextBlockBreakLongWords(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	token := "https://example.com/" + strings.Repeat("a1b2c3d4e5", 18)
+	require.Len(t, token, 200)
+
+	overflowing := NewTextBlock(token, TextBlockOpts{TextWrap: true})
+	w, _ := overflowing.IntrinsicSize(ctx, 300, 0)
+	assert.Greater(t, w, 300.0, "Unbroken token should overflow without BreakLongWords")
+
+	broken := NewTextBlock("See "+token+" for details", TextBlockOpts{TextWrap: true, BreakLongWords: true})
+	w, h := broken.IntrinsicSize(ctx, 300, 0)
+	assert.LessOrEqual(t, w, 300.0, "Broken token should stay within the column width")
+	lines := broken.wrap(ctx, 300)
+	assert.Greater(t, len(lines), 2)
+	assert.Equal(t, textHeight(ctx, len(lines)), h)
+	assert.Contains(t, strings.Join(lines, ""), token, "Broken lines should hold every character of the token in order")
+}
+
+func Test_TextBlockTabs(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	block := NewTextBlock("Name\tQty\nNecklace pendant\tQty", TextBlockOpts{Tabs: []float64{80, 200}})
+
+	_, short := block.tabSegments(ctx, "Name\tQty")
+	_, long := block.tabSegments(ctx, "Necklace pendant\tQty")
+	assert.Equal(t, 80.0, short[1], "Tab should advance to the first stop past the text")
+	assert.Equal(t, 200.0, long[1], "Tab should skip the stops the text already passed")
+
+	qtyW, _ := ctx.MeasureString("Qty")
+	w, _ := block.IntrinsicSize(ctx, 0, 0)
+	assert.Equal(t, 200+qtyW, w, "Width should reach the farthest used tab stop plus the last segment")
+
+	t.Run("Second segments align", func(t *testing.T) {
+		// inkStart returns the leftmost drawn column past the given x
+		inkStart := func(line string, from int) int {
+			ctx := gg.NewContext(400, 40)
+			ctx.SetColor(color.Black)
+			NewTextBlock(line, TextBlockOpts{Tabs: []float64{150}}).Draw(ctx, 400, 40)
+			img := ctx.Image()
+			for x := from; x < 400; x++ {
+				for y := range 40 {
+					if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+						return x
+					}
+				}
+			}
+			return -1
+		}
+		assert.Equal(t, inkStart("ID\tValue", 140), inkStart("Identifier\tValue", 140), "Segments after a tab should start at the same stop")
+	})
+}
+
+func Test_TextBlockTabExpansion(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	space, _ := ctx.MeasureString(" ")
+	plain, _ := NewTextBlock("Name Qty", TextBlockOpts{}).IntrinsicSize(ctx, 0, 0)
+	tabbed, _ := NewTextBlock("Name\tQty", TextBlockOpts{}).IntrinsicSize(ctx, 0, 0)
+	assert.Greater(t, tabbed, plain, "A tab should measure wider than a single space")
+
+	block := NewTextBlock("", TextBlockOpts{})
+	_, offsets := block.tabSegments(ctx, "ab\tc")
+	assert.InDelta(t, DefaultTabSize*space, offsets[1], 1e-9, "A tab should advance to the next multiple of the tab size")
+	_, offsets = block.tabSegments(ctx, "abcd\tc")
+	assert.InDelta(t, 2*DefaultTabSize*space, offsets[1], 1e-9, "Text reaching a stop should advance to the following one")
+	_, offsets = NewTextBlock("", TextBlockOpts{TabSize: 8}).tabSegments(ctx, "ab\tc")
+	assert.InDelta(t, 8*space, offsets[1], 1e-9)
+
+	// tabbed columns line up whatever the length of the text before the tab, and wrapping measures with the expansion
+	short := block.measureLine(ctx, "a\tValue")
+	long := block.measureLine(ctx, "abc\tValue")
+	assert.Equal(t, short, long)
+	wrapped := NewTextBlock("Name\tQty", TextBlockOpts{TextWrap: true})
+	w, _ := wrapped.IntrinsicSize(ctx, 1000, 0)
+	assert.Equal(t, tabbed, w)
+}
+
+func Test_TextBlockVAlign(t *testing.T) {
+	// inkRows returns the first and last rows with drawn pixels
+	inkRows := func(valign VAlign) (int, int) {
+		ctx := gg.NewContext(200, 200)
+		ctx.SetColor(color.Black)
+		NewTextBlock("Label", TextBlockOpts{VAlign: valign}).Draw(ctx, 200, 200)
+		img := ctx.Image()
+		first, last := -1, -1
+		for y := range 200 {
+			for x := range 200 {
+				if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+					if first < 0 {
+						first = y
+					}
+					last = y
+					break
+				}
+			}
+		}
+		return first, last
+	}
+
+	topFirst, _ := inkRows(VAlignTop)
+	assert.Less(t, topFirst, 20, "Top aligned text should be drawn at the top of the box")
+
+	first, last := inkRows(VAlignMiddle)
+	assert.InDelta(t, 100, float64(first+last)/2, 10, "Middle aligned text should be centered around half the box height")
+
+	_, bottomLast := inkRows(VAlignBottom)
+	assert.Greater(t, bottomLast, 180, "Bottom aligned text should be drawn at the bottom of the box")
+}
+
+func Test_TextBlockBox(t *testing.T) {
+	const pad = 10.0
+	red := color.RGBA{R: 255, A: 255}
+	ctx := gg.NewContext(400, 400)
+	plain := NewTextBlock("A speech bubble spanning a few wrapped lines of text", TextBlockOpts{TextWrap: true})
+	boxed := NewTextBlock(plain.Text, TextBlockOpts{TextWrap: true, BoxColor: red, BoxPadding: pad})
+
+	tw, th := plain.IntrinsicSize(ctx, 200-2*pad, 0)
+	bw, bh := boxed.IntrinsicSize(ctx, 200, 0)
+	assert.Equal(t, tw+2*pad, bw, "The box should add the padding to the wrapped text width")
+	assert.Equal(t, th+2*pad, bh, "The box should add the padding to the wrapped text height")
+	assert.Greater(t, th, ctx.FontHeight()*DefaultLineSpacing, "The text should be wrapped to several lines")
+
+	ctx.SetColor(color.Black)
+	boxed.Draw(ctx, 200, 0)
+	img := ctx.Image()
+	// the box spans the box width and the measured height, and the text is drawn inside the padding
+	bounds, ink := image.Rectangle{}, image.Rectangle{}
+	for y := range 400 {
+		for x := range 400 {
+			if r, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+				if r < 0x8000 {
+					ink = ink.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+	}
+	assert.Equal(t, image.Rect(0, 0, 200, int(math.Ceil(bh))), bounds, "The box should match the wrapped text bounds")
+	assert.False(t, ink.Empty(), "The text should be drawn over the box")
+	assert.True(t, ink.In(image.Rect(int(pad), int(pad), int(200-pad)+1, int(bh-pad)+1)), "The text %v should be enclosed within the box padding", ink)
+}
+
+func Test_TextBlockAutoShadow(t *testing.T) {
+	// shadowOf returns the shadow color picked for text of the given color
+	shadowOf := func(text color.Color) color.NRGBA {
+		block := NewTextBlock("Shadow", TextBlockOpts{Color: text, AutoShadow: true})
+		return block.shadowColor(gg.NewContext(1, 1)).(color.NRGBA)
+	}
+
+	light := shadowOf(color.White)
+	assert.Equal(t, color.NRGBA{A: autoShadowAlpha}, light, "Light text should get a dark shadow")
+	dark := shadowOf(color.NRGBA{R: 20, G: 20, B: 60, A: 255})
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: autoShadowAlpha}, dark, "Dark text should get a light shadow")
+	assert.Less(t, light.A, uint8(0xff), "The shadow should be subtle")
+
+	explicit := NewTextBlock("Shadow", TextBlockOpts{Color: color.White, AutoShadow: true, ShadowColor: color.White})
+	assert.Equal(t, color.White, explicit.shadowColor(gg.NewContext(1, 1)), "An explicit shadow color should win")
+	assert.Nil(t, NewTextBlock("Shadow", TextBlockOpts{}).shadowColor(gg.NewContext(1, 1)), "No shadow should be drawn by default")
+
+	// the shadow is drawn offset below the white text, so dark pixels appear over the gray background
+	ctx := gg.NewContext(100, 40)
+	ctx.SetColor(color.Gray{0x80})
+	ctx.Clear()
+	NewTextBlock("Shadow", TextBlockOpts{Color: color.White, AutoShadow: true, ShadowOffset: 2}).Draw(ctx, 100, 40)
+	darkest := uint32(0xffff)
+	for y := range 40 {
+		for x := range 100 {
+			r, _, _, _ := ctx.Image().At(x, y).RGBA()
+			darkest = min(darkest, r)
+		}
+	}
+	assert.Less(t, darkest, uint32(0x5000), "A dark shadow should be drawn behind light text")
+}
+
+func Test_TextBlockPreserveNewlines(t *testing.T) {
+	ctx := gg.NewContext(1024, 1024)
+	text := "Name\n\n  12 Main Street\nBuilding B, a very long line that is wrapped to the column width"
+
+	reflowed := NewTextBlock(text, TextBlockOpts{TextWrap: true})
+	lines := reflowed.wrap(ctx, 200)
+	assert.Equal(t, "12 Main Street", lines[1], "Blank lines and indentation should collapse without PreserveNewlines")
+
+	preserved := NewTextBlock(text, TextBlockOpts{TextWrap: true, PreserveNewlines: true})
+	lines = preserved.wrap(ctx, 200)
+	require.Greater(t, len(lines), 4, "The long line should still be wrapped")
+	assert.Equal(t, []string{"Name", "", "  12 Main Street"}, lines[:3])
+	_, h := preserved.IntrinsicSize(ctx, 200, 0)
+	assert.Equal(t, textHeight(ctx, len(lines)), h)
+
+	// inkRows counts the separate bands of rows holding dark pixels, one per drawn line of text
+	inkRows := func(block *TextBlock, cw float64) int {
+		ctx := gg.NewContext(400, 200)
+		ctx.SetColor(color.White)
+		ctx.Clear()
+		ctx.SetColor(color.Black)
+		block.Draw(ctx, cw, 0)
+		bands, inked := 0, false
+		for y := range 200 {
+			dark := false
+			for x := range 400 {
+				if r, _, _, _ := ctx.Image().At(x, y).RGBA(); r < 0x8000 {
+					dark = true
+					break
+				}
+			}
+			if dark && !inked {
+				bands++
+			}
+			inked = dark
+		}
+		return bands
+	}
+	assert.Equal(t, 3, inkRows(NewTextBlock("First\nSecond\n\nFourth", TextBlockOpts{PreserveNewlines: true}), 0),
+		"Each line should be drawn on its own, leaving the blank line empty")
+	assert.Equal(t, len(lines)-1, inkRows(preserved, 200), "Each wrapped line but the blank one should be drawn")
+}
+
+func Test_TextBlockLineHeight(t *testing.T) {
+	face, err := newFontFace(DefaultMinFontSize)
+	require.NoError(t, err)
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = "Hgjpy"
+	}
+	block := NewTextBlock(strings.Join(lines, "\n"), TextBlockOpts{TextWrap: true})
+
+	ctx := gg.NewContext(200, 600)
+	ctx.SetFontFace(face)
+	ctx.SetColor(color.White)
+	ctx.Clear()
+	ctx.SetColor(color.Black)
+	_, h := block.IntrinsicSize(ctx, 200, 0)
+	block.Draw(ctx, 200, 0)
+
+	bottom := 0
+	for y := range 600 {
+		for x := range 200 {
+			if r, _, _, _ := ctx.Image().At(x, y).RGBA(); r < 0xffff {
+				bottom = y + 1
+				break
+			}
+		}
+	}
+	ascent, descent := lineMetrics(ctx)
+	assert.Equal(t, (ascent+descent)*DefaultLineSpacing, lineHeight(ctx), "Lines should advance by the ascent and descent of the face")
+	assert.InDelta(t, float64(bottom), h, 1, "The measured height of 20 lines should match their drawn extent")
+}
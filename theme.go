@@ -0,0 +1,64 @@
+package imacon
+
+import (
+	"image/color"
+)
+
+// Theme is a reusable set of styling defaults, shared between engines so that colors and fonts aren't repeated on
+// every Config. The fields set on the Config take precedence, with the theme only filling in the unset ones.
+type Theme struct {
+	FgColor         color.Color  // The foreground color used for text and shapes, when Config.FgColor is unset
+	BgColor         color.Color  // The background color of the canvas, when Config.BgColor is unset
+	AccentColor     color.Color  // The color of dividers and the canvas border without a color of their own. Nil uses the foreground color.
+	CaptionColor    color.Color  // The color of image block labels without a color of their own. Nil uses the foreground color.
+	FontSize        float64      // The default font size for text rendering, when Config.FontSize is zero
+	CaptionFontSize float64      // The font size of image block labels, when Config.CaptionFontSize is zero
+	OuterPad        float64      // The padding around the content of the canvas. Zero defaults to DefaultOuterPad.
+	CanvasBorder    CanvasBorder // The border drawn around the canvas, when Config.CanvasBorder has no width
+	BoldFont        []byte       // The TrueType data of the font for bold markdown spans, when Config.BoldFont is nil
+	ItalicFont      []byte       // The TrueType data of the font for italic markdown spans, when Config.ItalicFont is nil
+}
+
+// apply returns the config with its unset style fields filled in from the theme.
+func (t *Theme) apply(cfg Config) Config {
+	if cfg.FgColor == nil {
+		cfg.FgColor = t.FgColor
+	}
+	if cfg.BgColor == nil {
+		cfg.BgColor = t.BgColor
+	}
+	if cfg.FontSize == 0 {
+		cfg.FontSize = t.FontSize
+	}
+	if cfg.CaptionFontSize == 0 {
+		cfg.CaptionFontSize = t.CaptionFontSize
+	}
+	if cfg.CanvasBorder.Width <= 0 {
+		cfg.CanvasBorder = t.CanvasBorder
+	}
+	if cfg.BoldFont == nil {
+		cfg.BoldFont = t.BoldFont
+	}
+	if cfg.ItalicFont == nil {
+		cfg.ItalicFont = t.ItalicFont
+	}
+	return cfg
+}
+
+// accentColor returns the given color of a divider or border, falling back to the theme accent color of the render.
+// Nil means the foreground color.
+func (s *renderState) accentColor(c color.Color) color.Color {
+	if c != nil || s.theme == nil {
+		return c
+	}
+	return s.theme.AccentColor
+}
+
+// captionColor returns the given color of an image block label, falling back to the theme caption color of the render.
+// Nil means the foreground color.
+func (s *renderState) captionColor(c color.Color) color.Color {
+	if c != nil || s.theme == nil {
+		return c
+	}
+	return s.theme.CaptionColor
+}
@@ -0,0 +1,124 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+)
+
+func Test_Theme(t *testing.T) {
+	theme := &Theme{
+		FgColor:      color.RGBA{R: 20, G: 30, B: 120, A: 255},
+		BgColor:      color.RGBA{R: 250, G: 245, B: 230, A: 255},
+		AccentColor:  color.RGBA{R: 200, G: 60, B: 20, A: 255},
+		CaptionColor: color.RGBA{G: 128, A: 255},
+		FontSize:     16,
+		OuterPad:     10,
+		CanvasBorder: CanvasBorder{Width: 2},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	newScene := func() *Scene {
+		return NewScene(NewPane([]Tileable{
+			NewDivider(4, nil),
+			NewTextBlock("Themed", TextBlockOpts{}),
+			NewImageBlockFromImage(img, "Caption"),
+		}, 100, 0, 0))
+	}
+	// styleOf samples the divider, border and background, and collects the colors the text and caption are drawn in
+	type style struct {
+		divider, border, background color.Color
+		ink                         map[color.Color]bool
+	}
+	styleOf := func(cfg Config) style {
+		c, err := New(cfg).Render(newScene())
+		require.NoError(t, err)
+		s := style{
+			divider:    c.Raw.At(int(theme.OuterPad)+1, int(theme.OuterPad)+1),
+			border:     c.Raw.At(0, c.Height/2),
+			background: c.Raw.At(5, c.Height/2),
+			ink:        map[color.Color]bool{},
+		}
+		for y := int(theme.OuterPad) + 8; y < c.Height-int(theme.OuterPad); y++ {
+			for x := int(theme.OuterPad); x < c.Width-int(theme.OuterPad); x++ {
+				s.ink[c.Raw.At(x, y)] = true
+			}
+		}
+		return s
+	}
+
+	small := styleOf(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Theme: theme})
+	large := styleOf(Config{MaxCanvasWidth: 8192, MaxCanvasHeight: 8192, DefaultJpegQuality: 90, Theme: theme})
+	assert.Equal(t, small, large, "Engines sharing a theme should style their output the same")
+	assert.Equal(t, color.RGBAModel.Convert(theme.AccentColor), small.divider, "Dividers should use the accent color")
+	assert.Equal(t, color.RGBAModel.Convert(theme.AccentColor), small.border, "The canvas border should use the accent color")
+	assert.Equal(t, color.RGBAModel.Convert(theme.BgColor), small.background)
+	assert.True(t, small.ink[theme.FgColor], "Text should be drawn in the theme foreground color")
+	assert.True(t, small.ink[theme.CaptionColor], "Image labels should be drawn in the theme caption color")
+
+	override := styleOf(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, BgColor: color.RGBA{A: 255}, FgColor: color.RGBA{R: 255, A: 255}, Theme: theme})
+	assert.Equal(t, color.RGBAModel.Convert(color.RGBA{A: 255}), override.background, "Config fields should take precedence over the theme")
+	assert.True(t, override.ink[color.RGBA{R: 255, A: 255}])
+	assert.False(t, override.ink[theme.FgColor])
+
+	engine := New(Config{Theme: theme})
+	theme.BgColor = color.Black
+	assert.Equal(t, color.RGBA{R: 250, G: 245, B: 230, A: 255}, engine.cfg.BgColor, "The theme should be copied by New")
+	assert.Equal(t, 16.0, engine.fontSize())
+}
+
+func Test_ThemeCaptionShadow(t *testing.T) {
+	theme := &Theme{
+		FgColor:      color.RGBA{R: 20, G: 20, B: 60, A: 255},
+		BgColor:      color.Gray{0x80},
+		CaptionColor: color.White,
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	block := NewImageBlockFromImage(img, "Caption")
+	block.Label.Opts.AutoShadow = true
+	block.Label.Opts.ShadowOffset = 2
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, Theme: theme}).Render(NewScene(NewPane([]Tileable{block}, 100, 0, 0)))
+	require.NoError(t, err)
+
+	// the light caption over the gray background should get a dark shadow, although the foreground color is dark
+	darkest := uint32(0xffff)
+	for y := c.Height / 2; y < c.Height; y++ {
+		for x := range c.Width {
+			r, _, _, _ := c.Raw.At(x, y).RGBA()
+			darkest = min(darkest, r)
+		}
+	}
+	assert.Less(t, darkest, uint32(0x5000), "A dark shadow should be drawn behind the light caption")
+}
+
+func Test_ThemeFonts(t *testing.T) {
+	theme := &Theme{BoldFont: gomonobold.TTF, ItalicFont: gomonoitalic.TTF}
+	scene := func() *Scene {
+		return NewScene(NewPane([]Tileable{NewTextBlock("A **bold** and *italic* word", TextBlockOpts{Markdown: true})}, 0, 0, 0))
+	}
+	render := func(cfg Config) *Canvas {
+		cfg.MaxCanvasWidth, cfg.MaxCanvasHeight = 4096, 4096
+		c, err := New(cfg).Render(scene())
+		require.NoError(t, err)
+		return c
+	}
+
+	themed := New(Config{Theme: theme})
+	fonts, err := themed.parseStyleFonts()
+	require.NoError(t, err)
+	assert.Contains(t, fonts, styleBold, "The theme bold font should be used")
+	assert.Contains(t, fonts, styleItalic, "The theme italic font should be used")
+	assert.Equal(t, render(Config{BoldFont: gomonobold.TTF, ItalicFont: gomonoitalic.TTF}).Raw, render(Config{Theme: theme}).Raw,
+		"Fonts from the theme should render like fonts set on the config")
+	assert.NotEqual(t, render(Config{}).Raw, render(Config{Theme: theme}).Raw)
+
+	override := New(Config{Theme: theme, BoldFont: []byte("not a font")})
+	_, err = override.parseStyleFonts()
+	assert.ErrorContains(t, err, "bold font", "Config fonts should take precedence over the theme")
+}
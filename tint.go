@@ -0,0 +1,41 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// ImageTint is a color multiplied into every image block, e.g. to theme the images of a dark-mode variant.
+type ImageTint struct {
+	Color    color.Color // The tint color. Nil draws no tint.
+	Strength float64     // How strongly the images are pulled toward the tint color, from 0 for none to 1 for a full multiply
+}
+
+// apply returns the image with the tint color multiplied into its pixels at the tint strength. Like a multiply blend,
+// white turns into the tint color and black stays black, while the alpha is kept, so that the transparent areas of
+// the image stay transparent. The image is returned as is without a tint.
+func (t ImageTint) apply(img image.Image) image.Image {
+	if t.Color == nil || t.Strength <= 0 {
+		return img
+	}
+	tint := color.NRGBAModel.Convert(t.Color).(color.NRGBA)
+	strength := math.Min(t.Strength, 1) * float64(tint.A) / 0xff
+	// factor returns the multiplier of a channel, pulled from 1 toward the channel of the tint color by the strength
+	factor := func(c uint8) float64 {
+		return 1 - strength + strength*float64(c)/0xff
+	}
+	fr, fg, fb := factor(tint.R), factor(tint.G), factor(tint.B)
+
+	bounds := img.Bounds()
+	tinted := image.NewRGBA(bounds)
+	draw.Draw(tinted, bounds, img, bounds.Min, draw.Src)
+	// the pixels are premultiplied, so scaling the color channels keeps them within the alpha
+	for i := 0; i < len(tinted.Pix); i += 4 {
+		tinted.Pix[i] = uint8(math.Round(float64(tinted.Pix[i]) * fr))
+		tinted.Pix[i+1] = uint8(math.Round(float64(tinted.Pix[i+1]) * fg))
+		tinted.Pix[i+2] = uint8(math.Round(float64(tinted.Pix[i+2]) * fb))
+	}
+	return tinted
+}
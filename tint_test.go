@@ -0,0 +1,65 @@
+package imacon
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ImageTint(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 200, B: 200, A: 255}), image.Point{}, draw.Src)
+	render := func(tint ImageTint) *Canvas {
+		scene := NewScene(NewPane([]Tileable{NewImageBlockFromImage(img, "")}, 0, 0, 0))
+		c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, ImageTint: tint}).Render(scene)
+		require.NoError(t, err)
+		return c
+	}
+	inside := int(DefaultOuterPad) + 50
+
+	plain := render(ImageTint{})
+	assert.Equal(t, color.RGBA{R: 200, G: 200, B: 200, A: 255}, plain.Raw.At(inside, inside), "Images should be untouched without a tint")
+
+	blue := ImageTint{Color: color.RGBA{B: 255, A: 255}, Strength: 0.5}
+	tinted := render(blue)
+	r, g, b, _ := tinted.Raw.At(inside, inside).RGBA()
+	assert.InDelta(t, 100, r>>8, 2, "Red should be multiplied toward the tint color by the strength")
+	assert.InDelta(t, 100, g>>8, 2, "Green should be multiplied toward the tint color by the strength")
+	assert.InDelta(t, 200, b>>8, 2, "The channels of the tint color should be kept")
+	assert.Equal(t, plain.Raw.At(1, 1), tinted.Raw.At(1, 1), "The tint should only cover the images")
+
+	// a white pixel takes the tint color, while a transparent one shows the canvas beneath
+	mixed := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	mixed.Set(0, 0, color.White)
+	tintedMixed := blue.apply(mixed)
+	r, g, b, a := tintedMixed.At(0, 0).RGBA()
+	assert.InDelta(t, 128, r>>8, 1, "White should be pulled halfway to the tint color")
+	assert.InDelta(t, 128, g>>8, 1)
+	assert.Equal(t, uint32(0xff), b>>8)
+	assert.Equal(t, uint32(0xffff), a)
+	assert.Equal(t, color.RGBA{}, color.RGBAModel.Convert(tintedMixed.At(1, 0)), "Transparent pixels should stay transparent")
+
+	transparent := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	scene := NewScene(NewPane([]Tileable{NewImageBlockFromImage(transparent, "")}, 0, 0, 0))
+	c, err := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, ImageTint: blue}).Render(scene)
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, c.Raw.At(inside, inside), "The background of a transparent image should not be tinted")
+
+	// a block drawn in several strips is tinted once, until the tint changes
+	tall := image.NewRGBA(image.Rect(0, 0, 100, 3*renderStripHeight))
+	block := NewImageBlockFromImage(tall, "")
+	stream := func(tint ImageTint) image.Image {
+		eng := New(Config{MaxCanvasWidth: 4096, MaxCanvasHeight: 4096, ImageTint: tint})
+		require.NoError(t, eng.RenderTo(NewScene(NewPane([]Tileable{block}, 0, 0, 0)), io.Discard, FormatPNG))
+		return block.tinted
+	}
+	first := stream(blue)
+	require.NotNil(t, first)
+	assert.Same(t, first, stream(blue), "The tinted image should be cached across strips and renders")
+	assert.NotSame(t, first, stream(ImageTint{Color: color.Black, Strength: 0.5}), "A new tint should tint the image again")
+}